@@ -11,11 +11,13 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/alexchny/sync-relay/internal/adapters/jwks"
 	"github.com/alexchny/sync-relay/internal/adapters/plaid"
 	"github.com/alexchny/sync-relay/internal/adapters/postgres"
 	"github.com/alexchny/sync-relay/internal/adapters/redis"
 	"github.com/alexchny/sync-relay/internal/api/handlers"
 	"github.com/alexchny/sync-relay/internal/config"
+	"github.com/alexchny/sync-relay/internal/ports"
 	"github.com/alexchny/sync-relay/internal/service"
 )
 
@@ -65,15 +67,58 @@ func main() {
 
 	// create adapters
 	itemRepo := postgres.NewItemRepo(db)
+	subscriptionRepo := postgres.NewWebhookSubscriptionRepo(db)
+	deliveryRepo := postgres.NewWebhookDeliveryRepo(db)
 	queueAdapter := redis.NewQueueAdapter(redisClient, "sync:jobs")
 	plaidAdapter := plaid.NewAdapter(cfg.PlaidClientID, cfg.PlaidSecret, cfg.PlaidEnv)
+	webhookVerifier := plaid.NewWebhookVerifier(plaid.NewKeyCache(plaidAdapter, cfg.PlaidWebhookKeyTTL))
+
+	txManager := postgres.NewTxManager(db)
+	outboxRepo := postgres.NewOutboxRepo(db)
+	eventBuilder := service.NewEventBuilder(cfg.EventMaxPayloadBytes)
 
 	// create services
-	accountService := service.NewAccountService(plaidAdapter, itemRepo, queueAdapter)
+	accountService := service.NewAccountService(
+		plaidAdapter,
+		itemRepo,
+		queueAdapter,
+		txManager,
+		outboxRepo,
+		eventBuilder,
+		cfg.ItemPurgeRetention,
+	)
+	webhookService := service.NewWebhookService(subscriptionRepo, deliveryRepo, cfg.WebhookAllowPrivateTargets)
+	txRepo := postgres.NewTransactionRepo(db, false)
+	transactionService := service.NewTransactionService(txRepo)
+	itemService := service.NewItemService(itemRepo, txRepo, queueAdapter, redis.NewLockAdapter(redisClient))
+	// end-user session tokens are optional
+	var sessionVerifier ports.SessionTokenVerifier
+	if cfg.JWTEnabled() {
+		var keySet *jwks.KeySet
+		if cfg.JWTJWKSURL != "" {
+			keySet = jwks.NewURLKeySet(cfg.JWTJWKSURL, cfg.JWTJWKSCacheTTL)
+		} else {
+			keySet = jwks.NewFileKeySet(cfg.JWTJWKSFile, cfg.JWTJWKSCacheTTL)
+		}
+		sessionVerifier = jwks.NewVerifier(keySet, cfg.JWTIssuer, cfg.JWTAudience, cfg.JWTUserClaim, cfg.JWTTenantClaim)
+		slog.Info("end-user session tokens enabled", "user_claim", cfg.JWTUserClaim, "tenant_claim", cfg.JWTTenantClaim)
+	}
+
+	authService := service.NewAuthService(postgres.NewTenantRepo(db), postgres.NewAPIKeyRepo(db), sessionVerifier)
+	plaidWebhookService := service.NewPlaidWebhookService(
+		itemRepo,
+		queueAdapter,
+		txManager,
+		outboxRepo,
+		eventBuilder,
+	)
 
 	// create handlers
 	accountHandler := handlers.NewAccountHandler(accountService)
-	webhookHandler := handlers.NewWebhookHandler(plaidAdapter, itemRepo, queueAdapter)
+	webhookHandler := handlers.NewWebhookHandler(webhookVerifier, plaidWebhookService)
+	subscriptionHandler := handlers.NewWebhookSubscriptionHandler(webhookService)
+	transactionHandler := handlers.NewTransactionHandler(transactionService)
+	itemHandler := handlers.NewItemHandler(itemService)
 
 	mux := http.NewServeMux()
 
@@ -82,9 +127,29 @@ func main() {
 		_, _ = w.Write([]byte("OK"))
 	})
 
+	// /api routes act for the tenant that owns the api key, the onboarding
+	// routes also take an end user's session token from the frontend
+	tenant := handlers.RequireAPIKey(authService)
+	endUser := handlers.AllowEndUser(authService)
+
 	// account onboarding routes
-	mux.HandleFunc("/api/link/token", accountHandler.CreateLinkToken)
-	mux.HandleFunc("/api/items", accountHandler.ConnectItem)
+	mux.Handle("/api/link/token", endUser(http.HandlerFunc(accountHandler.CreateLinkToken)))
+	mux.Handle("/api/items", endUser(http.HandlerFunc(accountHandler.ConnectItem)))
+	mux.Handle("GET /api/items", tenant(http.HandlerFunc(itemHandler.List)))
+	mux.Handle("GET /api/items/{id}", tenant(http.HandlerFunc(itemHandler.Get)))
+	mux.Handle("DELETE /api/items/{id}", tenant(http.HandlerFunc(accountHandler.DeleteItem)))
+	mux.Handle("POST /api/items/{id}/reconcile", tenant(http.HandlerFunc(accountHandler.ReconcileItem)))
+	mux.Handle("POST /api/items/{id}/link/token", tenant(http.HandlerFunc(accountHandler.CreateUpdateLinkToken)))
+	mux.Handle("POST /api/items/{id}/link/complete", tenant(http.HandlerFunc(accountHandler.CompleteItemRepair)))
+
+	// transaction query routes
+	mux.Handle("GET /api/transactions", tenant(http.HandlerFunc(transactionHandler.List)))
+
+	// outbound webhook subscription routes
+	mux.Handle("POST /api/webhooks", tenant(http.HandlerFunc(subscriptionHandler.Create)))
+	mux.Handle("GET /api/webhooks", tenant(http.HandlerFunc(subscriptionHandler.List)))
+	mux.Handle("DELETE /api/webhooks/{id}", tenant(http.HandlerFunc(subscriptionHandler.Delete)))
+	mux.Handle("GET /api/webhooks/{id}/deliveries", tenant(http.HandlerFunc(subscriptionHandler.ListDeliveries)))
 
 	// webhook routes
 	mux.HandleFunc("/webhooks/plaid", webhookHandler.HandlePlaidWebhook)
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/adapters/postgres"
+	"github.com/alexchny/sync-relay/internal/config"
+	"github.com/alexchny/sync-relay/internal/service"
+	"github.com/google/uuid"
+)
+
+const usage = `usage:
+  apikey create-tenant <name>          add a tenant and print its id
+  apikey create <tenant-id> <name>     issue a key for the tenant, shown only once
+  apikey revoke <key-id>               stop accepting a key`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// load config
+	cfg, err := config.Load()
+	if err != nil {
+		panic("failed to load config: " + err.Error())
+	}
+
+	// connect to database
+	db, err := postgres.NewDB(cfg.DatabaseURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to connect to db:", err)
+		os.Exit(1)
+	}
+	defer func() { _ = db.Close() }()
+
+	auth := service.NewAuthService(postgres.NewTenantRepo(db), postgres.NewAPIKeyRepo(db), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := run(ctx, auth, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, auth *service.AuthService, args []string) error {
+	switch args[0] {
+	case "create-tenant":
+		if len(args) < 2 {
+			return fmt.Errorf("create-tenant requires a name")
+		}
+		tenant, err := auth.CreateTenant(ctx, strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+		fmt.Println(tenant.ID)
+		return nil
+
+	case "create":
+		if len(args) < 3 {
+			return fmt.Errorf("create requires a tenant id and a name")
+		}
+		tenantID, err := uuid.Parse(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid tenant id: %s", args[1])
+		}
+		raw, key, err := auth.CreateAPIKey(ctx, tenantID, strings.Join(args[2:], " "))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("id:  %s\nkey: %s\n", key.ID, raw)
+		return nil
+
+	case "revoke":
+		if len(args) < 2 {
+			return fmt.Errorf("revoke requires a key id")
+		}
+		id, err := uuid.Parse(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid key id: %s", args[1])
+		}
+		if err := auth.RevokeAPIKey(ctx, id); err != nil {
+			return err
+		}
+		fmt.Println("revoked", args[1])
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/adapters/redis"
+	"github.com/alexchny/sync-relay/internal/config"
+)
+
+const usage = `usage:
+  dlq list [limit]       show dead-lettered jobs (default 50)
+  dlq replay <job-id>    put a dead job back on the queue
+  dlq replay-all         put every dead job back on the queue`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// load config
+	cfg, err := config.Load()
+	if err != nil {
+		panic("failed to load config: " + err.Error())
+	}
+
+	// connect to redis
+	redisClient, err := redis.NewClient(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to connect to redis:", err)
+		os.Exit(1)
+	}
+	defer func() { _ = redisClient.Close() }()
+
+	queueAdapter := redis.NewQueueAdapter(redisClient, "sync:jobs")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := run(ctx, queueAdapter, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, queue *redis.QueueAdapter, args []string) error {
+	switch args[0] {
+	case "list":
+		limit := int64(50)
+		if len(args) > 1 {
+			n, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid limit: %s", args[1])
+			}
+			limit = n
+		}
+
+		jobs, err := queue.ListDeadLetters(ctx, limit)
+		if err != nil {
+			return err
+		}
+		for _, job := range jobs {
+			fmt.Printf("%s\titem=%s\ttype=%s\tattempts=%d\terror=%s\n", job.ID, job.ItemID, job.JobType, job.Attempts, job.LastError)
+		}
+		return nil
+
+	case "replay":
+		if len(args) < 2 {
+			return fmt.Errorf("replay requires a job id")
+		}
+		if err := queue.ReplayDeadLetter(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Println("replayed", args[1])
+		return nil
+
+	case "replay-all":
+		jobs, err := queue.ListDeadLetters(ctx, 0)
+		if err != nil {
+			return err
+		}
+		replayed := 0
+		for _, job := range jobs {
+			if err := queue.ReplayDeadLetter(ctx, job.ID); err != nil {
+				// left in place for inspection
+				if errors.Is(err, redis.ErrJobUndecodable) {
+					fmt.Println("skipped", job.ID)
+					continue
+				}
+				return err
+			}
+			replayed++
+		}
+		fmt.Println("replayed", replayed, "jobs")
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
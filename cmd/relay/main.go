@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/adapters/kafka"
+	"github.com/alexchny/sync-relay/internal/adapters/nats"
+	"github.com/alexchny/sync-relay/internal/adapters/postgres"
+	"github.com/alexchny/sync-relay/internal/adapters/redis"
+	"github.com/alexchny/sync-relay/internal/adapters/webhook"
+	"github.com/alexchny/sync-relay/internal/cloudevents"
+	"github.com/alexchny/sync-relay/internal/config"
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/alexchny/sync-relay/internal/service"
+	"github.com/google/uuid"
+)
+
+func main() {
+	// load config
+	cfg, err := config.Load()
+	if err != nil {
+		panic("failed to load config: " + err.Error())
+	}
+
+	// setup logger
+	var logger *slog.Logger
+	if cfg.Env == "production" {
+		logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	} else {
+		logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+	slog.SetDefault(logger)
+
+	slog.Info("starting sync-relay outbox relay", "env", cfg.Env, "sinks", len(cfg.EventSinks))
+
+	// connect to database
+	db, err := postgres.NewDB(cfg.DatabaseURL)
+	if err != nil {
+		slog.Error("failed to connect to db", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			slog.Error("failed to close db", "error", err)
+		}
+	}()
+	slog.Info("connected to postgres")
+
+	// connect to redis
+	redisClient, err := redis.NewClient(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	if err != nil {
+		slog.Error("failed to connect to redis", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := redisClient.Close(); err != nil {
+			slog.Error("failed to close redis", "error", err)
+		}
+	}()
+	slog.Info("connected to redis")
+
+	// build every configured sink, events fan out to all of them
+	mode, _ := cloudevents.ParseMode(cfg.CloudEventsMode)
+	sinks := make([]service.Sink, 0, len(cfg.EventSinks))
+	for _, sinkCfg := range cfg.EventSinks {
+		var publisher ports.EventPublisher
+		switch sinkCfg.Name {
+		case "redis-streams":
+			publisher = redis.NewStreamPublisher(
+				redisClient,
+				cfg.RedisStreamPrefix,
+				cfg.RedisStreamPerTenant,
+				int64(cfg.RedisStreamMaxLen),
+				cfg.EventSource,
+				mode,
+			)
+		case "kafka":
+			kafkaPublisher, err := kafka.NewPublisher(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.KafkaAcks, cfg.EventSource, mode)
+			if err != nil {
+				slog.Error("failed to connect to kafka", "error", err)
+				os.Exit(1)
+			}
+			defer kafkaPublisher.Close()
+			slog.Info("connected to kafka", "topic", cfg.KafkaTopic, "acks", cfg.KafkaAcks)
+			publisher = kafkaPublisher
+		case "nats":
+			natsPublisher, err := nats.NewPublisher(
+				cfg.NatsURL,
+				cfg.NatsSubjectPrefix,
+				cfg.NatsStream,
+				cfg.NatsAutoProvision,
+				cfg.EventSource,
+				mode,
+			)
+			if err != nil {
+				slog.Error("failed to connect to nats", "error", err)
+				os.Exit(1)
+			}
+			defer natsPublisher.Close()
+			slog.Info("connected to nats", "stream", cfg.NatsStream)
+			publisher = natsPublisher
+		case "webhook":
+			publisher = webhook.NewPublisher(
+				postgres.NewWebhookSubscriptionRepo(db),
+				postgres.NewWebhookDeliveryRepo(db),
+				cfg.WebhookTimeout,
+				cfg.EventSource,
+				mode,
+				cfg.WebhookMaxAttempts,
+				cfg.WebhookAllowPrivateTargets,
+			)
+		default:
+			publisher = redis.NewPubSubPublisher(redisClient, "sync-events", cfg.EventSource)
+		}
+
+		filter := service.SinkFilter{MinAmountCents: int64(sinkCfg.MinAmountCents)}
+		for _, tenant := range sinkCfg.Tenants {
+			filter.TenantIDs = append(filter.TenantIDs, uuid.MustParse(tenant))
+		}
+		for _, eventType := range sinkCfg.EventTypes {
+			filter.EventTypes = append(filter.EventTypes, domain.EventType(eventType))
+		}
+
+		sinks = append(sinks, service.Sink{
+			Name:       sinkCfg.Name,
+			Publisher:  publisher,
+			Filter:     filter,
+			Policy:     service.FailurePolicy(sinkCfg.Policy),
+			BufferSize: sinkCfg.BufferSize,
+		})
+		slog.Info("event sink configured", "sink", sinkCfg.Name, "policy", sinkCfg.Policy)
+	}
+
+	fanOut := service.NewFanOutPublisher(sinks, service.RetryPolicy{
+		MaxAttempts: cfg.SinkAsyncMaxAttempts,
+		BaseDelay:   time.Second,
+		MaxDelay:    cfg.OutboxRetryMaxDelay,
+	})
+
+	relay := service.NewOutboxRelay(
+		postgres.NewOutboxRepo(db),
+		postgres.NewTxManager(db),
+		fanOut,
+		cfg.OutboxBatchSize,
+		cfg.OutboxPollInterval,
+		cfg.OutboxRetention,
+		cfg.OutboxRetryMaxDelay,
+		cfg.OutboxLeaseDuration,
+		cfg.OutboxMaxAttempts,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		relay.Run(ctx)
+	}()
+
+	fanOutDone := make(chan struct{})
+	go func() {
+		defer close(fanOutDone)
+		fanOut.Run(ctx)
+	}()
+
+	// per-sink delivery counters
+	var metricsServer *http.Server
+	if cfg.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		metricsServer = &http.Server{
+			Addr:              cfg.MetricsAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		go func() {
+			slog.Info("serving metrics", "addr", metricsServer.Addr)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("metrics server failed", "error", err)
+			}
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	// wait for shutdown
+	<-stop
+	slog.Info("shutdown signal received, stopping relay...")
+	cancel()
+
+	if metricsServer != nil {
+		_ = metricsServer.Close()
+	}
+
+	select {
+	case <-done:
+		<-fanOutDone
+	case <-time.After(10 * time.Second):
+		slog.Warn("relay did not stop in time")
+	}
+	slog.Info("shutdown complete")
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -12,6 +13,8 @@ import (
 	"github.com/alexchny/sync-relay/internal/adapters/postgres"
 	"github.com/alexchny/sync-relay/internal/adapters/redis"
 	"github.com/alexchny/sync-relay/internal/config"
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
 	"github.com/alexchny/sync-relay/internal/service"
 )
 
@@ -65,7 +68,11 @@ func main() {
 	plaidClient := plaid.NewAdapter(cfg.PlaidClientID, cfg.PlaidSecret, cfg.PlaidEnv)
 
 	itemRepo := postgres.NewItemRepo(db)
-	txRepo := postgres.NewTransactionRepo(db)
+	txRepo := postgres.NewTransactionRepo(db, cfg.PgNotifyEnabled)
+	reportRepo := postgres.NewReconciliationRepo(db)
+	txManager := postgres.NewTxManager(db)
+	outboxRepo := postgres.NewOutboxRepo(db)
+	eventBuilder := service.NewEventBuilder(cfg.EventMaxPayloadBytes)
 
 	// prod rate limits for /transactions/sync
 	// 2500 req/min per client, 50 req/min per item
@@ -75,11 +82,29 @@ func main() {
 	syncer := service.NewSyncer(
 		itemRepo,
 		txRepo,
+		txManager,
 		plaidClient,
 		lockAdapter,
-		queueAdapter,
+		outboxRepo,
+		eventBuilder,
 		globalLimiter,
 		itemLimiter,
+		cfg.LockTTL,
+	)
+
+	reconciler := service.NewReconciler(
+		itemRepo,
+		txRepo,
+		txManager,
+		reportRepo,
+		plaidClient,
+		lockAdapter,
+		outboxRepo,
+		eventBuilder,
+		globalLimiter,
+		itemLimiter,
+		cfg.ReconciliationWindow,
+		cfg.LockTTL,
 	)
 
 	// start worker loop
@@ -91,32 +116,39 @@ func main() {
 
 	slog.Info("starting workers", "count", cfg.WorkerConcurrency)
 
+	retryPolicy := service.RetryPolicy{
+		MaxAttempts: cfg.JobMaxAttempts,
+		BaseDelay:   cfg.JobRetryBaseDelay,
+		MaxDelay:    cfg.JobRetryMaxDelay,
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+
+	consumerIDs := make([]string, 0, cfg.WorkerConcurrency)
 	for i := 0; i < cfg.WorkerConcurrency; i++ {
-		go func(workerID int) {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					job, err := queueAdapter.Dequeue(ctx, 2*time.Second)
-					if err != nil {
-						slog.Error("queue error", "worker_id", workerID, "error", err)
-						time.Sleep(time.Second)
-						continue
-					}
-					if job == nil {
-						continue
-					}
+		consumerID := fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), i)
+		consumerIDs = append(consumerIDs, consumerID)
 
-					slog.Info("processing job", "worker_id", workerID, "item_id", job.ItemID)
-					if err := syncer.SyncItem(ctx, job.ItemID); err != nil {
-						slog.Error("sync failed", "worker_id", workerID, "item_id", job.ItemID, "error", err)
-					}
-				}
+		go runWorker(ctx, consumerID, queueAdapter, retryPolicy, func(ctx context.Context, job *domain.SyncJob) error {
+			switch job.JobType {
+			case domain.JobTypeReconciliation:
+				_, err := reconciler.ReconcileItem(ctx, job.ItemID)
+				return err
+			default:
+				return syncer.SyncItem(ctx, job.ItemID)
 			}
-		}(i)
+		})
 	}
 
+	// keep our consumers alive and recover jobs from dead ones
+	go runQueueMaintenance(ctx, queueAdapter, consumerIDs)
+
+	// delete removed items once their retention is up
+	go runItemPurge(ctx, itemRepo)
+
 	// wait for shutdown
 	<-stop
 	slog.Info("shutdown signal received, stopping workers...")
@@ -124,3 +156,116 @@ func main() {
 	time.Sleep(2 * time.Second)
 	slog.Info("shutdown complete")
 }
+
+func runWorker(ctx context.Context, consumerID string, queue ports.JobQueue, retryPolicy service.RetryPolicy, handle func(context.Context, *domain.SyncJob) error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			job, err := queue.Dequeue(ctx, consumerID, 2*time.Second)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("queue error", "consumer_id", consumerID, "error", err)
+					time.Sleep(time.Second)
+				}
+				continue
+			}
+			if job == nil {
+				continue
+			}
+
+			slog.Info("processing job", "consumer_id", consumerID, "job_id", job.ID, "item_id", job.ItemID, "job_type", job.JobType)
+			handleErr := handle(service.WithTraceID(ctx, job.TraceID), job)
+
+			// ack/nack must go through even when shutting down
+			settleCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+			switch {
+			case handleErr == nil:
+				if err := queue.Ack(settleCtx, job); err != nil {
+					slog.Error("failed to ack job", "consumer_id", consumerID, "job_id", job.ID, "error", err)
+				}
+			case ctx.Err() != nil:
+				// interrupted by shutdown, let another worker pick it up
+				if err := queue.Nack(settleCtx, job, 0); err != nil {
+					slog.Error("failed to nack job", "consumer_id", consumerID, "job_id", job.ID, "error", err)
+				}
+			default:
+				job.RecordFailure(handleErr)
+
+				if delay, ok := retryPolicy.NextRetry(job, handleErr); ok {
+					slog.Warn("job failed, retrying", "consumer_id", consumerID, "job_id", job.ID, "item_id", job.ItemID, "attempt", job.Attempts, "delay", delay, "error", handleErr)
+					if err := queue.Nack(settleCtx, job, delay); err != nil {
+						slog.Error("failed to schedule retry", "consumer_id", consumerID, "job_id", job.ID, "error", err)
+					}
+					break
+				}
+
+				slog.Error("job failed, moving to dead-letter queue", "consumer_id", consumerID, "job_id", job.ID, "item_id", job.ItemID, "job_type", job.JobType, "attempt", job.Attempts, "error", handleErr)
+				if err := queue.DeadLetter(settleCtx, job); err != nil {
+					slog.Error("failed to dead-letter job", "consumer_id", consumerID, "job_id", job.ID, "error", err)
+				}
+			}
+
+			cancel()
+		}
+	}
+}
+
+func runQueueMaintenance(ctx context.Context, queue ports.JobQueueMaintainer, consumerIDs []string) {
+	heartbeat := time.NewTicker(10 * time.Second)
+	defer heartbeat.Stop()
+
+	reaper := time.NewTicker(30 * time.Second)
+	defer reaper.Stop()
+
+	promoter := time.NewTicker(time.Second)
+	defer promoter.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if err := queue.Heartbeat(ctx, consumerIDs...); err != nil {
+				slog.Error("queue heartbeat failed", "error", err)
+			}
+		case <-promoter.C:
+			if _, err := queue.PromoteDue(ctx); err != nil {
+				slog.Error("failed to promote delayed jobs", "error", err)
+			}
+		case <-reaper.C:
+			recovered, err := queue.RequeueOrphaned(ctx)
+			if err != nil {
+				slog.Error("failed to requeue orphaned jobs", "error", err)
+				continue
+			}
+			if recovered > 0 {
+				slog.Warn("requeued jobs from dead consumers", "count", recovered)
+			}
+		}
+	}
+}
+
+func runItemPurge(ctx context.Context, itemRepo ports.ItemRepository) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		purged, err := itemRepo.PurgeRemoved(ctx, time.Now())
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("failed to purge removed items", "error", err)
+			}
+		} else if purged > 0 {
+			slog.Info("purged removed items", "count", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/alexchny/sync-relay/internal/service"
+	"github.com/google/uuid"
+)
+
+// oneJobQueue hands out a single job, then stops the worker
+type oneJobQueue struct {
+	job    *domain.SyncJob
+	stop   context.CancelFunc
+	settle string
+	delay  time.Duration
+}
+
+func (q *oneJobQueue) Enqueue(ctx context.Context, job *domain.SyncJob) error {
+	return nil
+}
+
+func (q *oneJobQueue) Dequeue(ctx context.Context, consumerID string, timeout time.Duration) (*domain.SyncJob, error) {
+	if q.job == nil {
+		q.stop()
+		return nil, nil
+	}
+	job := q.job
+	q.job = nil
+	return job, nil
+}
+
+func (q *oneJobQueue) Ack(ctx context.Context, job *domain.SyncJob) error {
+	q.settle = "ack"
+	return nil
+}
+
+func (q *oneJobQueue) Nack(ctx context.Context, job *domain.SyncJob, delay time.Duration) error {
+	q.settle = "nack"
+	q.delay = delay
+	return nil
+}
+
+func (q *oneJobQueue) DeadLetter(ctx context.Context, job *domain.SyncJob) error {
+	q.settle = "dead"
+	return nil
+}
+
+func TestRunWorkerSettlesJob(t *testing.T) {
+	policy := service.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}
+	transient := fmt.Errorf("sync failed: %w", ports.ErrPlaidUnavailable)
+
+	tests := []struct {
+		name         string
+		attempts     int
+		handleErr    error
+		wantSettle   string
+		wantAttempts int
+	}{
+		{"success", 0, nil, "ack", 0},
+		{"transient failure", 0, transient, "nack", 1},
+		{"transient failure out of attempts", 2, transient, "dead", 3},
+		{"permanent failure", 0, errors.New("item not found"), "dead", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			job := &domain.SyncJob{ID: uuid.NewString(), ItemID: uuid.New(), Attempts: tt.attempts}
+			queue := &oneJobQueue{job: job, stop: cancel}
+
+			runWorker(ctx, "worker-1", queue, policy, func(ctx context.Context, job *domain.SyncJob) error {
+				return tt.handleErr
+			})
+
+			if queue.settle != tt.wantSettle || job.Attempts != tt.wantAttempts {
+				t.Fatalf("settled %q after %d attempts, want %q after %d", queue.settle, job.Attempts, tt.wantSettle, tt.wantAttempts)
+			}
+			if queue.settle == "nack" && queue.delay < policy.BaseDelay {
+				t.Fatalf("retry delay = %s, want at least %s", queue.delay, policy.BaseDelay)
+			}
+			if tt.handleErr != nil && job.LastError != tt.handleErr.Error() {
+				t.Fatalf("last error = %q, want %q", job.LastError, tt.handleErr)
+			}
+		})
+	}
+}
+
+func TestRunWorkerRequeuesOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	job := &domain.SyncJob{ID: uuid.NewString(), ItemID: uuid.New()}
+	queue := &oneJobQueue{job: job, stop: cancel}
+
+	runWorker(ctx, "worker-1", queue, service.RetryPolicy{MaxAttempts: 3}, func(ctx context.Context, job *domain.SyncJob) error {
+		cancel()
+		return ctx.Err()
+	})
+
+	// an interrupted job isn't a failed attempt
+	if queue.settle != "nack" || queue.delay != 0 || job.Attempts != 0 {
+		t.Fatalf("settled %q with delay %s after %d attempts, want an immediate requeue", queue.settle, queue.delay, job.Attempts)
+	}
+}
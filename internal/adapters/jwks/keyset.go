@@ -0,0 +1,249 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrKeyNotFound = errors.New("signing key not found in jwks")
+
+// an unknown kid triggers a refresh, but not more often than this
+const minRefreshInterval = 30 * time.Second
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// KeySet caches the public keys of a JWKS document read from a url or a
+// local file, refreshing after the ttl or when a token names an unknown kid
+type KeySet struct {
+	load func(ctx context.Context) ([]byte, error)
+	ttl  time.Duration
+
+	// serializes refreshes so a burst of requests fetches once
+	refreshMu sync.Mutex
+
+	mu          sync.RWMutex
+	keys        map[string]any
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func NewURLKeySet(url string, ttl time.Duration) *KeySet {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return newKeySet(func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("jwks endpoint returned %d", resp.StatusCode)
+		}
+
+		return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	}, ttl)
+}
+
+func NewFileKeySet(path string, ttl time.Duration) *KeySet {
+	return newKeySet(func(ctx context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}, ttl)
+}
+
+func newKeySet(load func(ctx context.Context) ([]byte, error), ttl time.Duration) *KeySet {
+	return &KeySet{
+		load: load,
+		ttl:  ttl,
+		keys: make(map[string]any),
+	}
+}
+
+// Key returns the public key for kid. a token without a kid is accepted
+// when the set holds a single key.
+func (s *KeySet) Key(ctx context.Context, kid string) (any, error) {
+	key, fresh, ok := s.lookup(kid)
+	if ok && fresh {
+		return key, nil
+	}
+
+	if err := s.refresh(ctx, ok); err != nil {
+		// a stale key beats rejecting every request while the idp is down
+		if ok {
+			slog.Warn("jwks refresh failed, using cached key", "kid", kid, "error", err)
+			return key, nil
+		}
+		return nil, err
+	}
+
+	if key, _, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrKeyNotFound, kid)
+}
+
+func (s *KeySet) lookup(kid string) (key any, fresh, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fresh = time.Since(s.fetchedAt) < s.ttl
+
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, fresh, true
+		}
+	}
+
+	key, ok = s.keys[kid]
+	return key, fresh, ok
+}
+
+func (s *KeySet) refresh(ctx context.Context, known bool) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.mu.RLock()
+	fetchedAt, attemptedAt := s.fetchedAt, s.attemptedAt
+	s.mu.RUnlock()
+
+	// someone else refreshed while we waited
+	if time.Since(fetchedAt) < s.ttl && known {
+		return nil
+	}
+	// unknown kids mustn't let callers hammer the idp
+	if time.Since(attemptedAt) < minRefreshInterval {
+		if fetchedAt.IsZero() {
+			return fmt.Errorf("jwks unavailable, retrying after %s", minRefreshInterval)
+		}
+		return nil
+	}
+
+	s.mu.Lock()
+	s.attemptedAt = time.Now()
+	s.mu.Unlock()
+
+	raw, err := s.load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load jwks: %w", err)
+	}
+
+	keys, err := parseKeySet(raw)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	s.mu.Unlock()
+
+	slog.Info("jwks refreshed", "keys", len(keys))
+	return nil
+}
+
+func parseKeySet(raw []byte) (map[string]any, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode jwks: %w", err)
+	}
+
+	keys := make(map[string]any, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		// encryption keys and unsupported types aren't ours to use
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := parsePublicKey(jwk)
+		if err != nil {
+			slog.Warn("skipping jwks key", "kid", jwk.Kid, "error", err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks has no usable signing keys")
+	}
+
+	return keys, nil
+}
+
+func parsePublicKey(jwk jsonWebKey) (any, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsa modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rsa exponent: %w", err)
+		}
+
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 {
+			return nil, fmt.Errorf("invalid rsa exponent")
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(exponent.Int64()),
+		}, nil
+
+	case "EC":
+		// ES256 is the only curve we accept
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", jwk.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key y coordinate: %w", err)
+		}
+
+		// uncompressed SEC 1 point: 0x04 || X || Y
+		point := make([]byte, 0, 1+len(x)+len(y))
+		point = append(point, 0x04)
+		point = append(point, x...)
+		point = append(point, y...)
+
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+	}
+}
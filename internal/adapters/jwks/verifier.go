@@ -0,0 +1,72 @@
+package jwks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// tolerated clock skew between us and the identity provider
+const leeway = 30 * time.Second
+
+// Verifier checks end-user session tokens from the identity provider and
+// reads the end user and tenant out of the configured claims
+type Verifier struct {
+	keys        *KeySet
+	parser      *jwt.Parser
+	userClaim   string
+	tenantClaim string
+}
+
+func NewVerifier(keys *KeySet, issuer, audience, userClaim, tenantClaim string) *Verifier {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	return &Verifier{
+		keys:        keys,
+		parser:      jwt.NewParser(opts...),
+		userClaim:   userClaim,
+		tenantClaim: tenantClaim,
+	}
+}
+
+func (v *Verifier) VerifySessionToken(ctx context.Context, token string) (*ports.EndUserIdentity, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ports.ErrInvalidSessionToken, err)
+	}
+
+	endUserID, _ := claims[v.userClaim].(string)
+	if endUserID == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ports.ErrInvalidSessionToken, v.userClaim)
+	}
+
+	rawTenant, _ := claims[v.tenantClaim].(string)
+	tenantID, err := uuid.Parse(rawTenant)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s claim", ports.ErrInvalidSessionToken, v.tenantClaim)
+	}
+
+	return &ports.EndUserIdentity{
+		TenantID:  tenantID,
+		EndUserID: endUserID,
+	}, nil
+}
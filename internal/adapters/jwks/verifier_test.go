@@ -0,0 +1,283 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	testIssuer   = "https://idp.example.com/"
+	testAudience = "sync-relay"
+)
+
+func rsaJWK(t *testing.T, kid string, key *rsa.PublicKey) jsonWebKey {
+	t.Helper()
+	return jsonWebKey{
+		Kid: kid,
+		Kty: "RSA",
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(t *testing.T, kid string, key *ecdsa.PublicKey) jsonWebKey {
+	t.Helper()
+	point, err := key.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// uncompressed SEC 1 point: 0x04 || X || Y
+	size := (len(point) - 1) / 2
+	return jsonWebKey{
+		Kid: kid,
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(point[1 : 1+size]),
+		Y:   base64.RawURLEncoding.EncodeToString(point[1+size:]),
+	}
+}
+
+// staticKeySet serves the given keys and counts loads
+func staticKeySet(t *testing.T, loads *int, keys ...jsonWebKey) *KeySet {
+	t.Helper()
+	raw, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newKeySet(func(ctx context.Context) ([]byte, error) {
+		if loads != nil {
+			*loads++
+		}
+		return raw, nil
+	}, time.Hour)
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifySessionToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := staticKeySet(t, nil, rsaJWK(t, "rsa-1", &rsaKey.PublicKey), ecJWK(t, "ec-1", &ecKey.PublicKey))
+	verifier := NewVerifier(keys, testIssuer, testAudience, "sub", "tenant_id")
+
+	tenantID := uuid.New()
+	now := time.Now()
+	claims := func(edit func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":       testIssuer,
+			"aud":       testAudience,
+			"sub":       "user-42",
+			"tenant_id": tenantID.String(),
+			"exp":       now.Add(time.Hour).Unix(),
+			"iat":       now.Unix(),
+		}
+		if edit != nil {
+			edit(c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"rs256", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(nil)), false},
+		{"es256", sign(t, jwt.SigningMethodES256, ecKey, "ec-1", claims(nil)), false},
+		{"within leeway", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(func(c jwt.MapClaims) {
+			c["exp"] = now.Add(-10 * time.Second).Unix()
+		})), false},
+		{"expired", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(func(c jwt.MapClaims) {
+			c["exp"] = now.Add(-time.Hour).Unix()
+		})), true},
+		{"no exp", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(func(c jwt.MapClaims) {
+			delete(c, "exp")
+		})), true},
+		{"not yet valid", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(func(c jwt.MapClaims) {
+			c["nbf"] = now.Add(time.Hour).Unix()
+		})), true},
+		{"wrong issuer", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(func(c jwt.MapClaims) {
+			c["iss"] = "https://evil.example.com/"
+		})), true},
+		{"wrong audience", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(func(c jwt.MapClaims) {
+			c["aud"] = "someone-else"
+		})), true},
+		{"no audience", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(func(c jwt.MapClaims) {
+			delete(c, "aud")
+		})), true},
+		{"signed by another key", sign(t, jwt.SigningMethodRS256, otherKey, "rsa-1", claims(nil)), true},
+		{"hs256 with public key bytes", sign(t, jwt.SigningMethodHS256, rsaKey.N.Bytes(), "rsa-1", claims(nil)), true},
+		{"alg none", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "rsa-1", claims(nil)), true},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-2", claims(nil)), true},
+		{"missing user", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(func(c jwt.MapClaims) {
+			delete(c, "sub")
+		})), true},
+		{"bad tenant", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims(func(c jwt.MapClaims) {
+			c["tenant_id"] = "acme"
+		})), true},
+		{"garbage", "not.a.token", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := verifier.VerifySessionToken(context.Background(), tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, ports.ErrInvalidSessionToken) {
+					t.Fatalf("error = %v, want ErrInvalidSessionToken", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if identity.TenantID != tenantID || identity.EndUserID != "user-42" {
+				t.Fatalf("identity = %+v, want tenant %s user user-42", identity, tenantID)
+			}
+		})
+	}
+}
+
+func TestKeySetSingleKeyWithoutKid(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := staticKeySet(t, nil, rsaJWK(t, "only", &rsaKey.PublicKey))
+	verifier := NewVerifier(keys, "", "", "sub", "tenant_id")
+
+	token := sign(t, jwt.SigningMethodRS256, rsaKey, "", jwt.MapClaims{
+		"sub":       "user-1",
+		"tenant_id": uuid.NewString(),
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	})
+	if _, err := verifier.VerifySessionToken(context.Background(), token); err != nil {
+		t.Fatalf("token without kid rejected: %v", err)
+	}
+}
+
+func TestKeySetUnknownKidRefreshThrottled(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loads := 0
+	keys := staticKeySet(t, &loads, rsaJWK(t, "rsa-1", &rsaKey.PublicKey))
+
+	if _, err := keys.Key(context.Background(), "rsa-1"); err != nil {
+		t.Fatal(err)
+	}
+	for range 5 {
+		if _, err := keys.Key(context.Background(), "rotated"); !errors.Is(err, ErrKeyNotFound) {
+			t.Fatalf("error = %v, want ErrKeyNotFound", err)
+		}
+	}
+
+	// the first lookup loaded the set, unknown kids right after don't refetch
+	if loads != 1 {
+		t.Fatalf("loads = %d, want 1", loads)
+	}
+}
+
+func TestKeySetServesStaleKeyWhenRefreshFails(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := json.Marshal(map[string]any{"keys": []jsonWebKey{rsaJWK(t, "rsa-1", &rsaKey.PublicKey)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := false
+	keys := newKeySet(func(ctx context.Context) ([]byte, error) {
+		if down {
+			return nil, errors.New("idp unavailable")
+		}
+		return raw, nil
+	}, time.Hour)
+
+	if _, err := keys.Key(context.Background(), "rsa-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// expire the cache and take the idp down
+	keys.mu.Lock()
+	keys.fetchedAt = time.Now().Add(-2 * time.Hour)
+	keys.attemptedAt = time.Time{}
+	keys.mu.Unlock()
+	down = true
+
+	if _, err := keys.Key(context.Background(), "rsa-1"); err != nil {
+		t.Fatalf("stale key not served: %v", err)
+	}
+}
+
+func TestParseKeySetSkipsUnusableKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc := rsaJWK(t, "enc", &rsaKey.PublicKey)
+	enc.Use = "enc"
+	raw, err := json.Marshal(map[string]any{"keys": []jsonWebKey{
+		enc,
+		{Kid: "p384", Kty: "EC", Crv: "P-384", X: "AA", Y: "AA"},
+		{Kid: "oct", Kty: "oct"},
+		rsaJWK(t, "sig", &rsaKey.PublicKey),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := parseKeySet(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := keys["sig"]; !ok || len(keys) != 1 {
+		t.Fatalf("keys = %v, want only the rsa signing key", keys)
+	}
+
+	if _, err := parseKeySet([]byte(`{"keys":[{"kid":"oct","kty":"oct"}]}`)); err == nil {
+		t.Fatal("jwks without usable keys accepted")
+	}
+}
@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/cloudevents"
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Publisher produces events to one topic keyed by item id, so every event
+// for an item lands on the same partition in order.
+type Publisher struct {
+	client *kgo.Client
+	source string
+	mode   cloudevents.Mode
+}
+
+// acks is "all", "leader" or "none". the idempotent producer needs acks from
+// all in-sync replicas, so it is only enabled with "all".
+func NewPublisher(brokers []string, topic, acks string, source string, mode cloudevents.Mode) (*Publisher, error) {
+	opts, err := producerOpts(brokers, topic, acks)
+	if err != nil {
+		return nil, err
+	}
+	if acks != "all" {
+		slog.Warn("kafka idempotent producer disabled, retried produces may write duplicates", "acks", acks)
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to kafka: %w", err)
+	}
+
+	return &Publisher{
+		client: client,
+		source: source,
+		mode:   mode,
+	}, nil
+}
+
+func producerOpts(brokers []string, topic, acks string) ([]kgo.Opt, error) {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(brokers...),
+		kgo.DefaultProduceTopic(topic),
+		// never reorder records for a key while retrying
+		kgo.MaxProduceRequestsInflightPerBroker(1),
+		kgo.ProducerLinger(5 * time.Millisecond),
+	}
+
+	switch acks {
+	case "all":
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	case "leader":
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite())
+	case "none":
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()), kgo.DisableIdempotentWrite())
+	default:
+		return nil, fmt.Errorf("invalid kafka acks: %s", acks)
+	}
+
+	return opts, nil
+}
+
+func (p *Publisher) Publish(ctx context.Context, events ...*domain.Event) error {
+	records := make([]*kgo.Record, 0, len(events))
+
+	for _, e := range events {
+		msg, err := cloudevents.Encode(cloudevents.FromEvent(e, p.source), p.mode)
+		if err != nil {
+			return err
+		}
+
+		headers := []kgo.RecordHeader{
+			{Key: "content-type", Value: []byte(msg.ContentType)},
+		}
+		for name, value := range msg.PrefixedHeaders("ce_") {
+			headers = append(headers, kgo.RecordHeader{Key: name, Value: []byte(value)})
+		}
+
+		records = append(records, &kgo.Record{
+			Key:     []byte(e.ItemID.String()),
+			Value:   msg.Body,
+			Headers: headers,
+		})
+	}
+
+	if err := p.client.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		return fmt.Errorf("kafka produce failed: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Publisher) Close() {
+	p.client.Close()
+}
@@ -0,0 +1,126 @@
+package kafka
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/cloudevents"
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/google/uuid"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestProducerOpts(t *testing.T) {
+	tests := []struct {
+		acks           string
+		wantAcks       kgo.Acks
+		wantIdempotent bool
+	}{
+		{"all", kgo.AllISRAcks(), true},
+		// the idempotent producer needs every in-sync replica's ack
+		{"leader", kgo.LeaderAck(), false},
+		{"none", kgo.NoAck(), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acks, func(t *testing.T) {
+			opts, err := producerOpts([]string{"localhost:9092"}, "sync-events", tt.acks)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the client rejects combinations it can't honour, without dialing
+			client, err := kgo.NewClient(opts...)
+			if err != nil {
+				t.Fatalf("client rejected the options: %v", err)
+			}
+			defer client.Close()
+
+			if got := client.OptValue(kgo.RequiredAcks); got != tt.wantAcks {
+				t.Errorf("acks = %v, want %v", got, tt.wantAcks)
+			}
+			if disabled := client.OptValue(kgo.DisableIdempotentWrite); disabled != !tt.wantIdempotent {
+				t.Errorf("idempotence disabled = %v, want %v", disabled, !tt.wantIdempotent)
+			}
+			if got := client.OptValue(kgo.MaxProduceRequestsInflightPerBroker); got != 1 {
+				t.Errorf("inflight requests = %v, want 1 to keep an item's events in order", got)
+			}
+		})
+	}
+
+	if _, err := producerOpts([]string{"localhost:9092"}, "sync-events", "some"); err == nil {
+		t.Fatal("unknown acks accepted")
+	}
+}
+
+// runs against the compose profile, which auto-creates topics:
+// docker compose --profile kafka up -d && KAFKA_BROKERS=localhost:9092 go test ./internal/adapters/kafka
+func TestPublisherKeysByItem(t *testing.T) {
+	brokers := os.Getenv("KAFKA_BROKERS")
+	if brokers == "" {
+		t.Skip("KAFKA_BROKERS not set")
+	}
+	seeds := strings.Split(brokers, ",")
+	topic := "test-" + uuid.NewString()
+
+	p, err := NewPublisher(seeds, topic, "all", "sync-relay", cloudevents.ModeBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tenantID, itemID := uuid.New(), uuid.New()
+	first := domain.NewEvent(tenantID, itemID, domain.EventTypeTransactionsAdded, []byte(`{"changes":[]}`), "")
+	second := domain.NewEvent(tenantID, itemID, domain.EventTypeTransactionsModified, []byte(`{"changes":[]}`), "")
+	if err := p.Publish(ctx, first, second); err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(seeds...),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+
+	var records []*kgo.Record
+	for len(records) < 2 {
+		fetches := consumer.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("got %d records before timing out", len(records))
+		}
+		records = append(records, fetches.Records()...)
+	}
+
+	for i, want := range []*domain.Event{first, second} {
+		r := records[i]
+		if string(r.Key) != itemID.String() {
+			t.Fatalf("record key = %q, want the item id %s", r.Key, itemID)
+		}
+		if got := header(r, "ce_id"); got != want.ID.String() {
+			t.Fatalf("record %d ce_id = %q, want %s", i, got, want.ID)
+		}
+	}
+	// same key, same partition
+	if records[0].Partition != records[1].Partition {
+		t.Fatalf("an item's events landed on partitions %d and %d", records[0].Partition, records[1].Partition)
+	}
+}
+
+func header(r *kgo.Record, key string) string {
+	for _, h := range r.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
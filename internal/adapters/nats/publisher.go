@@ -0,0 +1,141 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alexchny/sync-relay/internal/cloudevents"
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Publisher publishes events to jetstream on <prefix>.<tenant>.<item>.<category>,
+// where category is "transactions" or "item".
+// the event id is the message id, so the stream drops redeliveries that land
+// inside its duplicate window.
+type Publisher struct {
+	conn   *nats.Conn
+	js     jetstream.JetStream
+	prefix string
+	source string
+	mode   cloudevents.Mode
+}
+
+// with provision set the stream is created, or updated to capture <prefix>.>,
+// at startup. otherwise it must already exist.
+func NewPublisher(url, prefix, stream string, provision bool, source string, mode cloudevents.Mode) (*Publisher, error) {
+	if err := validatePrefix(prefix); err != nil {
+		return nil, err
+	}
+
+	conn, err := nats.Connect(url, nats.Name("sync-relay"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create jetstream context: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if provision {
+		_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+			Name:       stream,
+			Subjects:   []string{prefix + ".>"},
+			Storage:    jetstream.FileStorage,
+			Duplicates: 10 * time.Minute,
+		})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to provision stream %s: %w", stream, err)
+		}
+	} else {
+		_, err = js.Stream(ctx, stream)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("stream %s is not available: %w", stream, err)
+		}
+	}
+
+	return &Publisher{
+		conn:   conn,
+		js:     js,
+		prefix: prefix,
+		source: source,
+		mode:   mode,
+	}, nil
+}
+
+func (p *Publisher) Publish(ctx context.Context, events ...*domain.Event) error {
+	for _, e := range events {
+		natsMsg, err := p.message(e)
+		if err != nil {
+			return err
+		}
+
+		if _, err := p.js.PublishMsg(ctx, natsMsg); err != nil {
+			return fmt.Errorf("jetstream publish to %s failed: %w", natsMsg.Subject, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Publisher) message(e *domain.Event) (*nats.Msg, error) {
+	msg, err := cloudevents.Encode(cloudevents.FromEvent(e, p.source), p.mode)
+	if err != nil {
+		return nil, err
+	}
+
+	natsMsg := nats.NewMsg(subject(p.prefix, e))
+	natsMsg.Data = msg.Body
+	natsMsg.Header.Set("Content-Type", msg.ContentType)
+	for name, value := range msg.PrefixedHeaders("ce-") {
+		natsMsg.Header.Set(name, value)
+	}
+	natsMsg.Header.Set(jetstream.MsgIDHeader, e.ID.String())
+
+	return natsMsg, nil
+}
+
+// tenant and item are uuids and never need escaping, the category comes
+// from the event type and does
+func subject(prefix string, e *domain.Event) string {
+	return fmt.Sprintf("%s.%s.%s.%s", prefix, e.TenantID, e.ItemID, subjectToken(e.Type.Category()))
+}
+
+// subjectToken keeps s to a single token with no wildcards
+func subjectToken(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '*' || r == '>' || unicode.IsSpace(r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// the prefix may span several tokens, but a wildcard or an empty token would
+// make every publish fail
+func validatePrefix(prefix string) error {
+	for _, token := range strings.Split(prefix, ".") {
+		if token == "" || subjectToken(token) != token {
+			return fmt.Errorf("invalid nats subject prefix: %q", prefix)
+		}
+	}
+	return nil
+}
+
+func (p *Publisher) Close() {
+	p.conn.Close()
+}
@@ -0,0 +1,149 @@
+package nats
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/cloudevents"
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func testEvent(eventType domain.EventType) *domain.Event {
+	return domain.NewEvent(uuid.New(), uuid.New(), eventType, []byte(`{"changes":[]}`), "")
+}
+
+func TestSubject(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    string
+		eventType domain.EventType
+		want      string
+	}{
+		{"transactions", "relay", domain.EventTypeTransactionsAdded, "transactions"},
+		{"item", "relay", domain.EventTypeItemError, "item"},
+		{"dotted prefix", "acme.relay", domain.EventTypeTransactionsRemoved, "transactions"},
+		// the category is the only free-form token
+		{"wildcards in category", "relay", domain.EventType("tx*>.added"), "tx__"},
+		{"space in category", "relay", domain.EventType("my tx.added"), "my_tx"},
+		{"empty category", "relay", domain.EventType(""), "_"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEvent(tt.eventType)
+
+			want := tt.prefix + "." + e.TenantID.String() + "." + e.ItemID.String() + "." + tt.want
+			if got := subject(tt.prefix, e); got != want {
+				t.Fatalf("subject = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidatePrefix(t *testing.T) {
+	for _, prefix := range []string{"relay", "acme.relay", "relay-events_1"} {
+		if err := validatePrefix(prefix); err != nil {
+			t.Errorf("validatePrefix(%q) = %v, want nil", prefix, err)
+		}
+	}
+	for _, prefix := range []string{"", "relay.", ".relay", "acme..relay", "relay.*", "relay.>", "my relay"} {
+		if err := validatePrefix(prefix); err == nil {
+			t.Errorf("validatePrefix(%q) succeeded, want an error", prefix)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	p := &Publisher{prefix: "relay", source: "sync-relay", mode: cloudevents.ModeBinary}
+	e := testEvent(domain.EventTypeTransactionsAdded)
+
+	msg, err := p.message(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// jetstream dedupes redeliveries on the event id
+	if got := msg.Header.Get("Nats-Msg-Id"); got != e.ID.String() {
+		t.Fatalf("Nats-Msg-Id = %q, want %s", got, e.ID)
+	}
+	if msg.Subject != subject("relay", e) {
+		t.Fatalf("subject = %q, want %q", msg.Subject, subject("relay", e))
+	}
+	if got := msg.Header.Get("ce-id"); got != e.ID.String() {
+		t.Fatalf("ce-id = %q, want %s", got, e.ID)
+	}
+	if string(msg.Data) != string(e.Payload) {
+		t.Fatalf("data = %s, want the payload", msg.Data)
+	}
+}
+
+// runs against the compose profile:
+// docker compose --profile nats up -d && NATS_URL=nats://localhost:4222 go test ./internal/adapters/nats
+func TestPublisherProvisionsStream(t *testing.T) {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		t.Skip("NATS_URL not set")
+	}
+
+	suffix := strings.ReplaceAll(uuid.NewString(), "-", "")
+	prefix := "test" + suffix
+	stream := "TEST_" + suffix
+
+	if _, err := NewPublisher(url, prefix, stream, false, "sync-relay", cloudevents.ModeBinary); err == nil {
+		t.Fatal("publisher started without the stream and without provisioning")
+	}
+
+	p, err := NewPublisher(url, prefix, stream, true, "sync-relay", cloudevents.ModeBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	t.Cleanup(func() { _ = p.js.DeleteStream(context.Background(), stream) })
+
+	s, err := p.js.Stream(ctx, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subjects := s.CachedInfo().Config.Subjects; len(subjects) != 1 || subjects[0] != prefix+".>" {
+		t.Fatalf("stream subjects = %v, want [%s.>]", subjects, prefix)
+	}
+
+	// a redelivery of the same event is dropped by the stream
+	e := testEvent(domain.EventTypeTransactionsAdded)
+	for range 2 {
+		if err := p.Publish(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := s.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 1 {
+		t.Fatalf("stream holds %d messages, want 1", info.State.Msgs)
+	}
+
+	msg, err := s.GetLastMsgForSubject(ctx, subject(prefix, e))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get(jetstream.MsgIDHeader); got != e.ID.String() {
+		t.Fatalf("Nats-Msg-Id = %q, want %s", got, e.ID)
+	}
+
+	// a second start with provisioning keeps the existing stream
+	again, err := NewPublisher(url, prefix, stream, true, "sync-relay", cloudevents.ModeBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.Close()
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net/http"
 	"time"
 
 	"github.com/alexchny/sync-relay/internal/domain"
@@ -47,25 +48,9 @@ func (a *Adapter) FetchSyncUpdates(ctx context.Context, accessToken, cursor stri
 		defer func() { _ = httpResp.Body.Close() }()
 	}
 	if err != nil {
-		var plaidErr plaid.GenericOpenAPIError
-		if errors.As(err, &plaidErr) {
-			var errModel plaid.PlaidError
-			if jsonErr := json.Unmarshal(plaidErr.Body(), &errModel); jsonErr == nil {
-				code := errModel.GetErrorCode()
-
-				switch code {
-				case "TRANSACTIONS_SYNC_MUTATION_LIMIT_EXCEEDED":
-					return nil, ports.ErrCursorReset
-				case "ITEM_LOGIN_REQUIRED",
-					"ITEM_LOCKED",
-					"USER_SETUP_REQUIRED",
-					"INVALID_ACCESS_TOKEN",
-					"ITEM_NOT_FOUND":
-					return nil, ports.ErrUserActionRequired
-				}
-			}
+		if mapped := mapTransactionsError(err, httpResp); mapped != nil {
+			return nil, mapped
 		}
-
 		return nil, fmt.Errorf("plaid sync failed: %w", err)
 	}
 
@@ -100,6 +85,112 @@ func (a *Adapter) FetchSyncUpdates(ctx context.Context, accessToken, cursor stri
 	return syncResp, nil
 }
 
+func (a *Adapter) FetchTransactions(ctx context.Context, accessToken string, start, end time.Time) ([]*domain.Transaction, error) {
+	const pageSize = 500
+
+	var txs []*domain.Transaction
+	for {
+		options := plaid.NewTransactionsGetRequestOptions()
+		options.SetCount(pageSize)
+		options.SetOffset(int32(len(txs)))
+
+		request := plaid.NewTransactionsGetRequest(accessToken, start.Format("2006-01-02"), end.Format("2006-01-02"))
+		request.SetOptions(*options)
+
+		resp, err := a.fetchTransactionsPage(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pTx := range resp.GetTransactions() {
+			tx, err := a.mapToDomain(pTx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to map transaction %s: %w", pTx.GetTransactionId(), err)
+			}
+			txs = append(txs, tx)
+		}
+
+		// pagination check
+		if len(resp.GetTransactions()) == 0 || len(txs) >= int(resp.GetTotalTransactions()) {
+			break
+		}
+	}
+
+	return txs, nil
+}
+
+func (a *Adapter) fetchTransactionsPage(ctx context.Context, request *plaid.TransactionsGetRequest) (*plaid.TransactionsGetResponse, error) {
+	resp, httpResp, err := a.client.PlaidApi.TransactionsGet(ctx).TransactionsGetRequest(*request).Execute()
+	if httpResp != nil && httpResp.Body != nil {
+		defer func() { _ = httpResp.Body.Close() }()
+	}
+	if err != nil {
+		if mapped := mapTransactionsError(err, httpResp); mapped != nil {
+			return nil, mapped
+		}
+		return nil, fmt.Errorf("plaid transactions get failed: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// maps plaid failures the sync flow reacts to onto port errors
+func mapTransactionsError(err error, httpResp *http.Response) error {
+	// keep plaid's own code and message whenever the body has them
+	apiErr := decodePlaidError(err)
+	if apiErr != nil {
+		err = apiErr
+	}
+
+	// no response or a 5xx means plaid (or the bank behind it) is having trouble
+	if httpResp == nil || httpResp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: %w", ports.ErrPlaidUnavailable, err)
+	}
+
+	if httpResp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("%w: %w", ports.ErrRateLimited, err)
+	}
+
+	if apiErr == nil {
+		return nil
+	}
+
+	switch apiErr.Code {
+	case "TRANSACTIONS_SYNC_MUTATION_LIMIT_EXCEEDED":
+		apiErr.Err = ports.ErrCursorReset
+	case "ITEM_LOGIN_REQUIRED",
+		"ITEM_LOCKED",
+		"USER_SETUP_REQUIRED",
+		"INVALID_ACCESS_TOKEN",
+		"ITEM_NOT_FOUND":
+		apiErr.Err = ports.ErrUserActionRequired
+	default:
+		// institution outages and the like come back as 400s, retry them
+		if domain.ClassifyPlaidErrorCode(apiErr.Code) == domain.ErrorCategoryTransient {
+			apiErr.Err = ports.ErrPlaidUnavailable
+		}
+	}
+
+	return apiErr
+}
+
+func decodePlaidError(err error) *ports.PlaidAPIError {
+	var plaidErr plaid.GenericOpenAPIError
+	if !errors.As(err, &plaidErr) {
+		return nil
+	}
+
+	var errModel plaid.PlaidError
+	if jsonErr := json.Unmarshal(plaidErr.Body(), &errModel); jsonErr != nil || errModel.GetErrorCode() == "" {
+		return nil
+	}
+
+	return &ports.PlaidAPIError{
+		Code:    errModel.GetErrorCode(),
+		Message: errModel.GetErrorMessage(),
+	}
+}
+
 func (a *Adapter) mapToDomain(pTx plaid.Transaction) (*domain.Transaction, error) {
 	var pendingID *string
 	if val, ok := pTx.GetPendingTransactionIdOk(); ok && val != nil {
@@ -137,6 +228,7 @@ func (a *Adapter) mapToDomain(pTx plaid.Transaction) (*domain.Transaction, error
 	return &domain.Transaction{
 		PlaidTransactionID: pTx.GetTransactionId(),
 		PlaidPendingID:     pendingID,
+		AccountID:          pTx.GetAccountId(),
 		AmountCents:        amountCents,
 		CurrencyCode:       currency,
 		MerchantName:       merchantName,
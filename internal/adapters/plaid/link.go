@@ -34,3 +34,56 @@ func (a *Adapter) CreateLinkToken(ctx context.Context, userID string) (string, e
 
 	return resp.GetLinkToken(), nil
 }
+
+// CreateUpdateLinkToken starts link in update mode for an existing item, so
+// the user can fix their login without creating a new item
+func (a *Adapter) CreateUpdateLinkToken(ctx context.Context, userID, accessToken string) (string, error) {
+	if userID == "" {
+		return "", fmt.Errorf("user ID cannot be empty")
+	}
+	if accessToken == "" {
+		return "", fmt.Errorf("access token cannot be empty")
+	}
+
+	user := plaid.LinkTokenCreateRequestUser{
+		ClientUserId: userID,
+	}
+
+	request := plaid.NewLinkTokenCreateRequest(
+		"sync-relay",
+		"en",
+		[]plaid.CountryCode{plaid.COUNTRYCODE_US},
+		user,
+	)
+	// update mode takes the item's access token instead of products
+	request.SetAccessToken(accessToken)
+
+	resp, httpResp, err := a.client.PlaidApi.LinkTokenCreate(ctx).LinkTokenCreateRequest(*request).Execute()
+	if httpResp != nil && httpResp.Body != nil {
+		defer func() { _ = httpResp.Body.Close() }()
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create update link token: %w", err)
+	}
+
+	return resp.GetLinkToken(), nil
+}
+
+func (a *Adapter) RemoveItem(ctx context.Context, accessToken string) error {
+	request := plaid.NewItemRemoveRequest(accessToken)
+
+	_, httpResp, err := a.client.PlaidApi.ItemRemove(ctx).ItemRemoveRequest(*request).Execute()
+	if httpResp != nil && httpResp.Body != nil {
+		defer func() { _ = httpResp.Body.Close() }()
+	}
+	if err != nil {
+		// already gone on plaid's side
+		if apiErr := decodePlaidError(err); apiErr != nil &&
+			(apiErr.Code == "ITEM_NOT_FOUND" || apiErr.Code == "INVALID_ACCESS_TOKEN") {
+			return nil
+		}
+		return fmt.Errorf("failed to remove item: %w", err)
+	}
+
+	return nil
+}
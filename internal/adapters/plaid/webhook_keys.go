@@ -0,0 +1,192 @@
+package plaid
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/plaid/plaid-go/v20/plaid"
+)
+
+func (a *Adapter) GetVerificationKey(ctx context.Context, keyID string) (*ports.WebhookVerificationKey, error) {
+	if keyID == "" {
+		return nil, fmt.Errorf("key ID cannot be empty")
+	}
+
+	request := plaid.NewWebhookVerificationKeyGetRequest(keyID)
+
+	resp, httpResp, err := a.client.PlaidApi.WebhookVerificationKeyGet(ctx).WebhookVerificationKeyGetRequest(*request).Execute()
+	if httpResp != nil && httpResp.Body != nil {
+		defer func() { _ = httpResp.Body.Close() }()
+	}
+	if err != nil {
+		var plaidErr plaid.GenericOpenAPIError
+		if errors.As(err, &plaidErr) {
+			var errModel plaid.PlaidError
+			if jsonErr := json.Unmarshal(plaidErr.Body(), &errModel); jsonErr == nil {
+				if errModel.GetErrorCode() == "INVALID_WEBHOOK_VERIFICATION_KEY_ID" {
+					return nil, ports.ErrVerificationKeyNotFound
+				}
+			}
+		}
+		return nil, fmt.Errorf("failed to fetch webhook verification key: %w", err)
+	}
+
+	return mapVerificationKey(resp.GetKey())
+}
+
+func mapVerificationKey(jwk plaid.JWKPublicKey) (*ports.WebhookVerificationKey, error) {
+	if jwk.GetKty() != "EC" || jwk.GetCrv() != "P-256" {
+		return nil, fmt.Errorf("unsupported key type %s/%s", jwk.GetKty(), jwk.GetCrv())
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk.GetX())
+	if err != nil {
+		return nil, fmt.Errorf("invalid key x coordinate: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.GetY())
+	if err != nil {
+		return nil, fmt.Errorf("invalid key y coordinate: %w", err)
+	}
+
+	// uncompressed SEC 1 point: 0x04 || X || Y
+	point := make([]byte, 0, 1+len(x)+len(y))
+	point = append(point, 0x04)
+	point = append(point, x...)
+	point = append(point, y...)
+
+	pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	key := &ports.WebhookVerificationKey{
+		KeyID:     jwk.GetKid(),
+		PublicKey: pub,
+		CreatedAt: time.Unix(int64(jwk.GetCreatedAt()), 0),
+	}
+
+	if expiredAt, ok := jwk.GetExpiredAtOk(); ok && expiredAt != nil {
+		t := time.Unix(int64(*expiredAt), 0)
+		key.ExpiredAt = &t
+	}
+
+	return key, nil
+}
+
+const (
+	// how long a kid plaid said doesn't exist is answered from memory
+	missingKeyTTL  = 5 * time.Minute
+	maxMissingKeys = 1024
+	// unknown kids are attacker controlled, they reach plaid through a token
+	// bucket. the burst leaves room for a real rotation while forged kids are
+	// trickling in at the refill rate
+	unknownKeyLookupInterval = time.Second
+	unknownKeyLookupBurst    = 10
+)
+
+var errKeyLookupThrottled = errors.New("verification key lookup throttled")
+
+type cachedKey struct {
+	key       *ports.WebhookVerificationKey
+	fetchedAt time.Time
+}
+
+type KeyCache struct {
+	source ports.WebhookKeyStore
+	ttl    time.Duration
+
+	mu             sync.RWMutex
+	keys           map[string]cachedKey
+	missing        map[string]time.Time
+	lookupTokens   float64
+	lookupRefillAt time.Time
+}
+
+func NewKeyCache(source ports.WebhookKeyStore, ttl time.Duration) *KeyCache {
+	return &KeyCache{
+		source:       source,
+		ttl:          ttl,
+		keys:         make(map[string]cachedKey),
+		missing:      make(map[string]time.Time),
+		lookupTokens: unknownKeyLookupBurst,
+	}
+}
+
+func (c *KeyCache) GetVerificationKey(ctx context.Context, keyID string) (*ports.WebhookVerificationKey, error) {
+	now := time.Now()
+
+	c.mu.RLock()
+	entry, known := c.keys[keyID]
+	missingAt, missing := c.missing[keyID]
+	c.mu.RUnlock()
+
+	if known && now.Sub(entry.fetchedAt) < c.ttl && !entry.key.IsExpired(now) {
+		return entry.key, nil
+	}
+	if missing && now.Sub(missingAt) < missingKeyTTL {
+		return nil, ports.ErrVerificationKeyNotFound
+	}
+
+	if !known && !c.allowUnknownLookup(now) {
+		return nil, errKeyLookupThrottled
+	}
+
+	key, err := c.source.GetVerificationKey(ctx, keyID)
+	if errors.Is(err, ports.ErrVerificationKeyNotFound) {
+		c.rememberMissing(keyID, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.keys[keyID] = cachedKey{key: key, fetchedAt: now}
+	delete(c.missing, keyID)
+	c.mu.Unlock()
+
+	return key, nil
+}
+
+func (c *KeyCache) allowUnknownLookup(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.lookupRefillAt.IsZero() {
+		refill := float64(now.Sub(c.lookupRefillAt)) / float64(unknownKeyLookupInterval)
+		c.lookupTokens = min(c.lookupTokens+refill, unknownKeyLookupBurst)
+	}
+	c.lookupRefillAt = now
+
+	if c.lookupTokens < 1 {
+		return false
+	}
+	c.lookupTokens--
+	return true
+}
+
+func (c *KeyCache) rememberMissing(keyID string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// forged kids are unbounded, keep the map from growing with them
+	if len(c.missing) >= maxMissingKeys {
+		for id, at := range c.missing {
+			if now.Sub(at) >= missingKeyTTL {
+				delete(c.missing, id)
+			}
+		}
+		if len(c.missing) >= maxMissingKeys {
+			clear(c.missing)
+		}
+	}
+
+	c.missing[keyID] = now
+}
@@ -0,0 +1,67 @@
+package plaid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/ports"
+)
+
+type countingKeyStore struct {
+	staticKeyStore
+	calls int
+}
+
+func (s *countingKeyStore) GetVerificationKey(ctx context.Context, keyID string) (*ports.WebhookVerificationKey, error) {
+	s.calls++
+	return s.staticKeyStore.GetVerificationKey(ctx, keyID)
+}
+
+func TestKeyCacheUnknownKids(t *testing.T) {
+	store := &countingKeyStore{staticKeyStore: staticKeyStore{}}
+	cache := NewKeyCache(store, 0)
+	ctx := context.Background()
+
+	if _, err := cache.GetVerificationKey(ctx, "forged-0"); !errors.Is(err, ports.ErrVerificationKeyNotFound) {
+		t.Fatalf("first lookup error = %v, want not found", err)
+	}
+
+	// answered from the negative cache
+	if _, err := cache.GetVerificationKey(ctx, "forged-0"); !errors.Is(err, ports.ErrVerificationKeyNotFound) {
+		t.Fatalf("repeat lookup error = %v, want not found", err)
+	}
+
+	// fresh kids use up the burst, then they are throttled instead of reaching plaid
+	for i := 1; i < unknownKeyLookupBurst; i++ {
+		if _, err := cache.GetVerificationKey(ctx, fmt.Sprintf("forged-%d", i)); !errors.Is(err, ports.ErrVerificationKeyNotFound) {
+			t.Fatalf("kid %d error = %v, want not found", i, err)
+		}
+	}
+	if _, err := cache.GetVerificationKey(ctx, "forged-burst"); !errors.Is(err, errKeyLookupThrottled) {
+		t.Fatalf("kid past the burst error = %v, want throttled", err)
+	}
+
+	if store.calls != unknownKeyLookupBurst {
+		t.Fatalf("upstream calls = %d, want %d", store.calls, unknownKeyLookupBurst)
+	}
+}
+
+func TestKeyCacheLookupBucketKeepsBurstUnderTrickle(t *testing.T) {
+	cache := NewKeyCache(staticKeyStore{}, 0)
+	now := time.Now()
+
+	// a forged kid every refill interval never drains the bucket, so a real
+	// rotated kid arriving in between still gets looked up
+	for i := 0; i < 3*unknownKeyLookupBurst; i++ {
+		now = now.Add(unknownKeyLookupInterval)
+		if !cache.allowUnknownLookup(now) {
+			t.Fatalf("trickled lookup %d throttled", i)
+		}
+	}
+	if !cache.allowUnknownLookup(now) {
+		t.Fatal("real kid throttled by trickled forged kids")
+	}
+}
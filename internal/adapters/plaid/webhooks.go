@@ -1,16 +1,39 @@
 package plaid
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-func (a *Adapter) VerifyWebhook(ctx context.Context, req *http.Request) (*ports.WebhookPayload, error) {
+// plaid recommends rejecting webhooks older than 5 minutes
+const maxWebhookAge = 5 * time.Minute
+
+type webhookClaims struct {
+	RequestBodySHA256 string `json:"request_body_sha256"`
+	jwt.RegisteredClaims
+}
+
+type WebhookVerifier struct {
+	keys ports.WebhookKeyStore
+}
+
+func NewWebhookVerifier(keys ports.WebhookKeyStore) *WebhookVerifier {
+	return &WebhookVerifier{keys: keys}
+}
+
+func (v *WebhookVerifier) VerifyWebhook(ctx context.Context, req *http.Request) (*ports.WebhookPayload, error) {
 	// method validation
 	if req.Method != http.MethodPost {
 		return nil, fmt.Errorf("invalid method: %s", req.Method)
@@ -23,19 +46,27 @@ func (a *Adapter) VerifyWebhook(ctx context.Context, req *http.Request) (*ports.
 	}
 
 	// plaid-veriff header validation
-	jwt := req.Header.Get("Plaid-Verification")
-	if jwt == "" {
+	token := req.Header.Get("Plaid-Verification")
+	if token == "" {
 		return nil, fmt.Errorf("missing Plaid-Verification header")
 	}
 
-	// TODO: add full cryptographic verification for JWT
-
 	// doS protection
 	req.Body = http.MaxBytesReader(nil, req.Body, 1048576)
 
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook body: %w", err)
+	}
+
+	// signature, age and body hash validation
+	if err := v.verifyToken(ctx, token, body); err != nil {
+		return nil, err
+	}
+
 	// parse body
 	var payload ports.WebhookPayload
-	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&payload); err != nil {
 		return nil, fmt.Errorf("failed to decode webhook body: %w", err)
 	}
 
@@ -46,3 +77,49 @@ func (a *Adapter) VerifyWebhook(ctx context.Context, req *http.Request) (*ports.
 
 	return &payload, nil
 }
+
+func (v *WebhookVerifier) verifyToken(ctx context.Context, token string, body []byte) error {
+	var claims webhookClaims
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}),
+		jwt.WithIssuedAt(),
+	)
+
+	_, err := parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, ok := t.Header["kid"].(string)
+		if !ok || kid == "" {
+			return nil, fmt.Errorf("missing kid header")
+		}
+
+		key, err := v.keys.GetVerificationKey(ctx, kid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get verification key %s: %w", kid, err)
+		}
+		if key.IsExpired(time.Now()) {
+			return nil, fmt.Errorf("verification key %s has expired", kid)
+		}
+
+		return key.PublicKey, nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalid webhook signature: %w", err)
+	}
+
+	// replay protection
+	if claims.IssuedAt == nil {
+		return fmt.Errorf("webhook token missing iat claim")
+	}
+	if time.Since(claims.IssuedAt.Time) > maxWebhookAge {
+		return fmt.Errorf("webhook token too old: issued at %s", claims.IssuedAt.Time.Format(time.RFC3339))
+	}
+
+	// body integrity
+	sum := sha256.Sum256(body)
+	expected := hex.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(claims.RequestBodySHA256)) != 1 {
+		return fmt.Errorf("webhook body hash mismatch")
+	}
+
+	return nil
+}
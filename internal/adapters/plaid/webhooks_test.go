@@ -0,0 +1,114 @@
+package plaid
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type staticKeyStore map[string]*ports.WebhookVerificationKey
+
+func (s staticKeyStore) GetVerificationKey(_ context.Context, keyID string) (*ports.WebhookVerificationKey, error) {
+	key, ok := s[keyID]
+	if !ok {
+		return nil, ports.ErrVerificationKeyNotFound
+	}
+	return key, nil
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := staticKeyStore{
+		"kid-1": {KeyID: "kid-1", PublicKey: &signingKey.PublicKey, CreatedAt: time.Now()},
+	}
+
+	body := `{"webhook_type":"TRANSACTIONS","webhook_code":"SYNC_UPDATES_AVAILABLE","item_id":"item-1"}`
+	bodyHash := func(b string) string {
+		sum := sha256.Sum256([]byte(b))
+		return hex.EncodeToString(sum[:])
+	}
+
+	sign := func(method jwt.SigningMethod, key any, kid string, issuedAt time.Time, hash string) string {
+		token := jwt.NewWithClaims(method, webhookClaims{
+			RequestBodySHA256: hash,
+			RegisteredClaims:  jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(issuedAt)},
+		})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			token: sign(jwt.SigningMethodES256, signingKey, "kid-1", time.Now(), bodyHash(body)),
+		},
+		{
+			name:    "stale iat",
+			token:   sign(jwt.SigningMethodES256, signingKey, "kid-1", time.Now().Add(-10*time.Minute), bodyHash(body)),
+			wantErr: "too old",
+		},
+		{
+			name:    "body hash mismatch",
+			token:   sign(jwt.SigningMethodES256, signingKey, "kid-1", time.Now(), bodyHash(body+" ")),
+			wantErr: "body hash mismatch",
+		},
+		{
+			name:    "unknown kid",
+			token:   sign(jwt.SigningMethodES256, signingKey, "kid-2", time.Now(), bodyHash(body)),
+			wantErr: ports.ErrVerificationKeyNotFound.Error(),
+		},
+		{
+			name:    "wrong alg",
+			token:   sign(jwt.SigningMethodHS256, []byte("secret"), "kid-1", time.Now(), bodyHash(body)),
+			wantErr: "signing method HS256 is invalid",
+		},
+	}
+
+	verifier := NewWebhookVerifier(keys)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/plaid", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Plaid-Verification", tt.token)
+
+			payload, err := verifier.VerifyWebhook(context.Background(), req)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if payload.ItemID != "item-1" {
+					t.Fatalf("item_id = %q, want item-1", payload.ItemID)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/alexchny/sync-relay/internal/domain"
 	"github.com/alexchny/sync-relay/internal/ports"
@@ -20,26 +22,48 @@ func NewItemRepo(db *DB) *ItemRepo {
 	return &ItemRepo{db: db}
 }
 
-func (r *ItemRepo) scanItem(row *sql.Row) (*domain.Item, error) {
+const itemColumns = `
+	id, tenant_id, end_user_id, plaid_item_id, access_token_enc,
+	sync_status, next_cursor, error_message, error_code, error_category,
+	error_first_seen_at, error_retry_count, consent_expires_at, purge_after, last_synced_at,
+	initial_update_complete, historical_update_complete,
+	created_at, updated_at
+`
+
+func (r *ItemRepo) scanItem(row scanner) (*domain.Item, error) {
 	var item domain.Item
 	var lastSyncedAt sql.NullTime
 	var errorMessage sql.NullString
+	var errorCode sql.NullString
+	var errorCategory sql.NullString
+	var errorFirstSeenAt sql.NullTime
+	var consentExpiresAt sql.NullTime
+	var purgeAfter sql.NullTime
 
 	err := row.Scan(
 		&item.ID,
 		&item.TenantID,
+		&item.EndUserID,
 		&item.PlaidItemID,
 		&item.AccessTokenEnc,
 		&item.SyncStatus,
 		&item.NextCursor,
 		&errorMessage,
+		&errorCode,
+		&errorCategory,
+		&errorFirstSeenAt,
+		&item.ErrorRetryCount,
+		&consentExpiresAt,
+		&purgeAfter,
 		&lastSyncedAt,
+		&item.InitialUpdateComplete,
+		&item.HistoricalUpdateComplete,
 		&item.CreatedAt,
 		&item.UpdatedAt,
 	)
 
 	if errors.Is(err, sql.ErrNoRows) {
-		return nil, fmt.Errorf("item not found: %w", err)
+		return nil, ports.ErrItemNotFound
 	}
 	if err != nil {
 		return nil, err
@@ -54,42 +78,54 @@ func (r *ItemRepo) scanItem(row *sql.Row) (*domain.Item, error) {
 		item.ErrorMessage = errorMessage.String
 	}
 
+	if errorCode.Valid {
+		item.ErrorCode = errorCode.String
+	}
+
+	if errorCategory.Valid {
+		item.ErrorCategory = domain.ErrorCategory(errorCategory.String)
+	}
+
+	if errorFirstSeenAt.Valid {
+		t := errorFirstSeenAt.Time
+		item.ErrorFirstSeenAt = &t
+	}
+
+	if consentExpiresAt.Valid {
+		t := consentExpiresAt.Time
+		item.ConsentExpiresAt = &t
+	}
+
+	if purgeAfter.Valid {
+		t := purgeAfter.Time
+		item.PurgeAfter = &t
+	}
+
 	return &item, nil
 }
 
 func (r *ItemRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Item, error) {
-	query := `
-		SELECT 
-			id, tenant_id, plaid_item_id, access_token_enc, 
-			sync_status, next_cursor, error_message, last_synced_at, 
-			created_at, updated_at
-		FROM items WHERE id = $1
-	`
+	query := `SELECT ` + itemColumns + ` FROM items WHERE id = $1`
 
-	return r.scanItem(r.db.QueryRowContext(ctx, query, id))
+	return r.scanItem(r.db.conn(ctx).QueryRowContext(ctx, query, id))
 }
 
 func (r *ItemRepo) GetByPlaidItemID(ctx context.Context, plaidItemID string) (*domain.Item, error) {
-	query := `
-		SELECT 
-			id, tenant_id, plaid_item_id, access_token_enc, 
-			sync_status, next_cursor, error_message, last_synced_at, 
-			created_at, updated_at
-		FROM items WHERE plaid_item_id = $1
-	`
-	return r.scanItem(r.db.QueryRowContext(ctx, query, plaidItemID))
+	query := `SELECT ` + itemColumns + ` FROM items WHERE plaid_item_id = $1`
+	return r.scanItem(r.db.conn(ctx).QueryRowContext(ctx, query, plaidItemID))
 }
 
 func (r *ItemRepo) Create(ctx context.Context, item *domain.Item) error {
 	query := `
 		INSERT INTO items (
-			id, tenant_id, plaid_item_id, access_token_enc, 
+			id, tenant_id, end_user_id, plaid_item_id, access_token_enc,
 			sync_status, next_cursor, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
 	`
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := r.db.conn(ctx).ExecContext(ctx, query,
 		item.ID,
 		item.TenantID,
+		item.EndUserID,
 		item.PlaidItemID,
 		item.AccessTokenEnc,
 		item.SyncStatus,
@@ -105,46 +141,310 @@ func (r *ItemRepo) Create(ctx context.Context, item *domain.Item) error {
 	return nil
 }
 
-func (r *ItemRepo) UpdateSuccess(ctx context.Context, id uuid.UUID, cursor string) error {
+func (r *ItemRepo) UpdateSuccess(ctx context.Context, id uuid.UUID, cursor string, fencingToken int64) error {
+	// a holder with an older token lost the lock, don't let it move the cursor.
+	// a sync that finishes after a webhook moved the item to error or
+	// disconnected it mustn't revive it or clear the webhook's error.
 	query := `
 		UPDATE items 
 		SET next_cursor = $1, 
 		    sync_status = 'active', 
 		    error_message = NULL,
+		    error_code = NULL,
+		    error_category = NULL,
+		    error_first_seen_at = NULL,
+		    error_retry_count = 0,
+		    fencing_token = $2,
 		    last_synced_at = NOW(), 
 		    updated_at = NOW()
-		WHERE id = $2
+		WHERE id = $3 AND fencing_token <= $2 AND sync_status IN ('active', 'resyncing')
 	`
-	_, err := r.db.ExecContext(ctx, query, cursor, id)
-	return err
+	res, err := r.db.conn(ctx).ExecContext(ctx, query, cursor, fencingToken, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return r.updateMissReason(ctx, id, fencingToken)
+	}
+
+	return nil
+}
+
+func (r *ItemRepo) AdvanceFence(ctx context.Context, id uuid.UUID, fencingToken int64) error {
+	query := `
+		UPDATE items
+		SET fencing_token = $1
+		WHERE id = $2 AND fencing_token <= $1 AND sync_status IN ('active', 'resyncing')
+	`
+	res, err := r.db.conn(ctx).ExecContext(ctx, query, fencingToken, id)
+	if err != nil {
+		return fmt.Errorf("failed to advance fencing token: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return r.updateMissReason(ctx, id, fencingToken)
+	}
+
+	return nil
 }
 
-func (r *ItemRepo) MarkResyncing(ctx context.Context, id uuid.UUID) error {
+// updateMissReason tells a disconnected item apart from a lost lock and from
+// a status another writer changed, only the lost lock is worth retrying
+func (r *ItemRepo) updateMissReason(ctx context.Context, id uuid.UUID, fencingToken int64) error {
+	var status domain.SyncStatus
+	var current int64
+	err := r.db.conn(ctx).QueryRowContext(ctx, `SELECT sync_status, fencing_token FROM items WHERE id = $1`, id).Scan(&status, &current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ports.ErrItemNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load item status: %w", err)
+	}
+
+	if status == domain.SyncStatusRevoked || status == domain.SyncStatusRemoved {
+		return ports.ErrItemInactive
+	}
+	if current > fencingToken {
+		return ports.ErrStaleFencingToken
+	}
+
+	return ports.ErrItemStateChanged
+}
+
+func (r *ItemRepo) MarkResyncing(ctx context.Context, id uuid.UUID, fencingToken int64) error {
+	// only a syncable item starts a replay, one that a webhook moved to error
+	// or disconnected in the meantime keeps its status
 	query := `
 		UPDATE items 
 		SET sync_status = 'resyncing', 
+		    fencing_token = $1,
 		    updated_at = NOW() 
-		WHERE id = $1
+		WHERE id = $2 AND fencing_token <= $1 AND sync_status IN ('active', 'resyncing')
 	`
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
+	return r.execFenced(ctx, id, fencingToken, query, fencingToken, id)
 }
 
-func (r *ItemRepo) MarkError(ctx context.Context, id uuid.UUID, syncErr error) error {
-	var errText string
-	if syncErr != nil {
-		errText = syncErr.Error()
-	} else {
-		errText = "unknown error"
-	}
-
+func (r *ItemRepo) MarkError(ctx context.Context, id uuid.UUID, itemErr domain.ItemError, fencingToken int64) error {
+	// same fence as UpdateSuccess, and a sync that fails because the item was
+	// disconnected under it mustn't pull it back out of revoked or removed
 	query := `
 		UPDATE items 
 		SET sync_status = 'error', 
-		    error_message = $1,
+		    error_code = NULLIF($1, ''),
+		    error_category = $2,
+		    error_message = $3,
+		    error_first_seen_at = COALESCE(error_first_seen_at, NOW()),
+		    error_retry_count = error_retry_count + 1,
+		    fencing_token = $4,
 		    updated_at = NOW() 
-		WHERE id = $2
+		WHERE id = $5 AND fencing_token <= $4 AND sync_status NOT IN ('revoked', 'removed')
+	`
+	return r.execFenced(ctx, id, fencingToken, query, itemErr.Code, itemErr.Category, itemErr.Message, fencingToken, id)
+}
+
+func (r *ItemRepo) RecordTransientError(ctx context.Context, id uuid.UUID, itemErr domain.ItemError, fencingToken int64) error {
+	query := `
+		UPDATE items 
+		SET error_code = NULLIF($1, ''),
+		    error_category = $2,
+		    error_message = $3,
+		    error_first_seen_at = COALESCE(error_first_seen_at, NOW()),
+		    error_retry_count = error_retry_count + 1,
+		    fencing_token = $4,
+		    updated_at = NOW() 
+		WHERE id = $5 AND fencing_token <= $4 AND sync_status NOT IN ('revoked', 'removed')
+	`
+	return r.execFenced(ctx, id, fencingToken, query, itemErr.Code, itemErr.Category, itemErr.Message, fencingToken, id)
+}
+
+// execFenced runs a fenced update and explains a miss like UpdateSuccess does
+func (r *ItemRepo) execFenced(ctx context.Context, id uuid.UUID, fencingToken int64, query string, args ...interface{}) error {
+	res, err := r.db.conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return r.updateMissReason(ctx, id, fencingToken)
+	}
+
+	return nil
+}
+
+func (r *ItemRepo) SetUpdateFlags(ctx context.Context, id uuid.UUID, initialComplete, historicalComplete bool) error {
+	query := `
+		UPDATE items
+		SET initial_update_complete = initial_update_complete OR $1,
+		    historical_update_complete = historical_update_complete OR $2,
+		    updated_at = NOW()
+		WHERE id = $3
 	`
-	_, err := r.db.ExecContext(ctx, query, errText, id)
+	_, err := r.db.conn(ctx).ExecContext(ctx, query, initialComplete, historicalComplete, id)
 	return err
 }
+
+// lifecycle transitions only apply from the statuses they expect and only
+// write the columns they change, so they can't undo a concurrent sync's or
+// webhook's writes
+
+func (r *ItemRepo) ReportError(ctx context.Context, id uuid.UUID, itemErr domain.ItemError) error {
+	query := `
+		UPDATE items
+		SET sync_status = 'error',
+		    error_code = NULLIF($1, ''),
+		    error_category = $2,
+		    error_message = $3,
+		    error_first_seen_at = COALESCE(error_first_seen_at, NOW()),
+		    updated_at = NOW()
+		WHERE id = $4 AND sync_status IN ('active', 'resyncing', 'error')
+	`
+	return r.execTransition(ctx, id, query, itemErr.Code, itemErr.Category, itemErr.Message, id)
+}
+
+func (r *ItemRepo) Reactivate(ctx context.Context, id uuid.UUID) error {
+	// a resyncing item is left to its replay, which ends in active anyway
+	query := `
+		UPDATE items
+		SET sync_status = 'active',
+		    error_message = NULL,
+		    error_code = NULL,
+		    error_category = NULL,
+		    error_first_seen_at = NULL,
+		    error_retry_count = 0,
+		    updated_at = NOW()
+		WHERE id = $1 AND sync_status IN ('active', 'error')
+	`
+	return r.execTransition(ctx, id, query, id)
+}
+
+func (r *ItemRepo) SetConsentExpiration(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	query := `
+		UPDATE items
+		SET consent_expires_at = $1,
+		    updated_at = NOW()
+		WHERE id = $2 AND sync_status NOT IN ('revoked', 'removed')
+	`
+	return r.execTransition(ctx, id, query, expiresAt, id)
+}
+
+func (r *ItemRepo) Revoke(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE items
+		SET sync_status = 'revoked',
+		    access_token_enc = '',
+		    updated_at = NOW()
+		WHERE id = $1 AND sync_status IN ('active', 'resyncing', 'error')
+	`
+	return r.execTransition(ctx, id, query, id)
+}
+
+func (r *ItemRepo) Remove(ctx context.Context, id uuid.UUID, purgeAfter time.Time) error {
+	query := `
+		UPDATE items
+		SET sync_status = 'removed',
+		    access_token_enc = '',
+		    purge_after = $1,
+		    updated_at = NOW()
+		WHERE id = $2 AND sync_status IN ('active', 'resyncing', 'error', 'revoked')
+	`
+	return r.execTransition(ctx, id, query, purgeAfter, id)
+}
+
+// execTransition runs a lifecycle update and explains a miss
+func (r *ItemRepo) execTransition(ctx context.Context, id uuid.UUID, query string, args ...interface{}) error {
+	res, err := r.db.conn(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update item lifecycle: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	var status domain.SyncStatus
+	err = r.db.conn(ctx).QueryRowContext(ctx, `SELECT sync_status FROM items WHERE id = $1`, id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ports.ErrItemNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load item status: %w", err)
+	}
+
+	if status == domain.SyncStatusRevoked || status == domain.SyncStatusRemoved {
+		return ports.ErrItemInactive
+	}
+	return ports.ErrItemStateChanged
+}
+
+func (r *ItemRepo) List(ctx context.Context, filter ports.ItemFilter) ([]*domain.Item, error) {
+	conds := []string{"tenant_id = $1"}
+	args := []interface{}{filter.TenantID}
+
+	if filter.EndUserID != "" {
+		args = append(args, filter.EndUserID)
+		conds = append(conds, fmt.Sprintf("end_user_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conds = append(conds, fmt.Sprintf("sync_status = $%d", len(args)))
+	}
+	if filter.After != nil {
+		// an unknown cursor item matches nothing rather than restarting the list
+		args = append(args, *filter.After)
+		conds = append(conds, fmt.Sprintf(
+			"(created_at, id) < (SELECT created_at, id FROM items WHERE id = $%d AND tenant_id = $1)", len(args)))
+	}
+
+	args = append(args, filter.Limit)
+	query := `SELECT ` + itemColumns + `
+		FROM items
+		WHERE ` + strings.Join(conds, " AND ") + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $` + fmt.Sprint(len(args))
+
+	rows, err := r.db.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query items: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	items := make([]*domain.Item, 0, filter.Limit)
+	for rows.Next() {
+		item, err := r.scanItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func (r *ItemRepo) PurgeRemoved(ctx context.Context, now time.Time) (int64, error) {
+	query := `
+		DELETE FROM items
+		WHERE sync_status = 'removed' AND purge_after <= $1
+	`
+	res, err := r.db.conn(ctx).ExecContext(ctx, query, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge removed items: %w", err)
+	}
+	return res.RowsAffected()
+}
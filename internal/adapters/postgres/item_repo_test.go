@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+// the repos' guarantees live in their sql, so these run against a real
+// postgres: TEST_DATABASE_URL=postgres://... go test ./internal/adapters/postgres
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	admin, err := NewDB(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+
+	// every test migrates its own schema and drops it afterwards
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", schema)) })
+
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	db, err := NewDB(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	migrations, err := filepath.Glob("../../../migrations/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(migrations)
+	for _, path := range migrations {
+		migration, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(path), err)
+		}
+	}
+
+	return db
+}
+
+func createTestItem(t *testing.T, repo *ItemRepo) *domain.Item {
+	t.Helper()
+
+	item := &domain.Item{
+		ID:             uuid.New(),
+		TenantID:       uuid.New(),
+		PlaidItemID:    "item-" + uuid.NewString(),
+		AccessTokenEnc: "access-token",
+		SyncStatus:     domain.SyncStatusActive,
+	}
+	if err := repo.Create(context.Background(), item); err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+func TestItemRepoSyncKeepsWebhookError(t *testing.T) {
+	ctx := context.Background()
+	repo := NewItemRepo(newTestDB(t))
+	item := createTestItem(t, repo)
+
+	// a sync holds fence 1 and writes its first page
+	if err := repo.AdvanceFence(ctx, item.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// an ITEM ERROR webhook lands mid-sync
+	itemErr := domain.ItemError{Code: "ITEM_LOGIN_REQUIRED", Category: domain.ClassifyPlaidErrorCode("ITEM_LOGIN_REQUIRED"), Message: "login required"}
+	if err := repo.ReportError(ctx, item.ID, itemErr); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.AdvanceFence(ctx, item.ID, 1); !errors.Is(err, ports.ErrItemStateChanged) {
+		t.Fatalf("AdvanceFence = %v, want ErrItemStateChanged", err)
+	}
+	if err := repo.UpdateSuccess(ctx, item.ID, "cursor-1", 1); !errors.Is(err, ports.ErrItemStateChanged) {
+		t.Fatalf("UpdateSuccess = %v, want ErrItemStateChanged", err)
+	}
+
+	got, err := repo.GetByID(ctx, item.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SyncStatus != domain.SyncStatusError || got.ErrorCode != itemErr.Code || got.ErrorFirstSeenAt == nil || got.NextCursor != "" {
+		t.Fatalf("item %+v, want the webhook's error and no cursor", got)
+	}
+}
+
+func TestItemRepoUpdateSuccessFencing(t *testing.T) {
+	ctx := context.Background()
+	repo := NewItemRepo(newTestDB(t))
+	item := createTestItem(t, repo)
+
+	if err := repo.UpdateSuccess(ctx, item.ID, "cursor-2", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateSuccess(ctx, item.ID, "cursor-1", 1); !errors.Is(err, ports.ErrStaleFencingToken) {
+		t.Fatalf("stale UpdateSuccess = %v, want ErrStaleFencingToken", err)
+	}
+
+	if err := repo.Revoke(ctx, item.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateSuccess(ctx, item.ID, "cursor-3", 3); !errors.Is(err, ports.ErrItemInactive) {
+		t.Fatalf("UpdateSuccess on a revoked item = %v, want ErrItemInactive", err)
+	}
+
+	got, err := repo.GetByID(ctx, item.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.NextCursor != "cursor-2" || got.SyncStatus != domain.SyncStatusRevoked {
+		t.Fatalf("cursor %q status %s, want cursor-2 and revoked", got.NextCursor, got.SyncStatus)
+	}
+}
@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/lib/pq"
+)
+
+// arbitrary key for pg_try_advisory_xact_lock, one relay claims a batch at a
+// time and leases it so events for an item can't overtake each other
+const outboxRelayLockKey = 7260531
+
+type OutboxRepo struct {
+	db *DB
+}
+
+func NewOutboxRepo(db *DB) *OutboxRepo {
+	return &OutboxRepo{db: db}
+}
+
+// Publish writes events to the outbox. inside a TxManager transaction the
+// events commit or roll back together with the rest of the work.
+func (r *OutboxRepo) Publish(ctx context.Context, events ...*domain.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	values := []interface{}{}
+	placeholders := []string{}
+
+	const paramsPerEvent = 7
+
+	for i, e := range events {
+		base := i * paramsPerEvent
+
+		row := fmt.Sprintf(
+			"($%d, $%d, $%d, $%d, $%d, $%d, $%d, NOW())",
+			base+1, base+2, base+3, base+4, base+5, base+6, base+7,
+		)
+		placeholders = append(placeholders, row)
+
+		values = append(values,
+			e.ID,
+			e.TenantID,
+			e.ItemID,
+			e.Type,
+			e.Payload,
+			e.TraceID,
+			e.CreatedAt,
+		)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO outbox (
+			event_id, tenant_id, item_id, event_type, payload, trace_id, created_at, next_attempt_at
+		)
+		VALUES %s
+	`, strings.Join(placeholders, ","))
+
+	if _, err := r.db.conn(ctx).ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("failed to write outbox events: %w", err)
+	}
+
+	return nil
+}
+
+func (r *OutboxRepo) ClaimRelay(ctx context.Context) (bool, error) {
+	var claimed bool
+	err := r.db.conn(ctx).QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxRelayLockKey).Scan(&claimed)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim outbox relay lock: %w", err)
+	}
+	return claimed, nil
+}
+
+func (r *OutboxRepo) FetchPending(ctx context.Context, limit int) ([]*domain.OutboxEntry, error) {
+	query := `
+		SELECT
+			o.id, o.event_id, o.tenant_id, o.item_id, o.event_type, o.payload,
+			o.trace_id, o.created_at, o.attempts, o.next_attempt_at, o.delivered_sinks
+		FROM outbox o
+		WHERE o.sent_at IS NULL
+		  AND o.parked_at IS NULL
+		  AND o.next_attempt_at <= NOW()
+		  AND NOT EXISTS (
+			SELECT 1 FROM outbox earlier
+			WHERE earlier.item_id = o.item_id
+			  AND earlier.sent_at IS NULL
+			  AND earlier.parked_at IS NULL
+			  AND earlier.id < o.id
+			  AND earlier.next_attempt_at > NOW()
+		  )
+		ORDER BY o.id
+		LIMIT $1
+	`
+
+	rows, err := r.db.conn(ctx).QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch outbox events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []*domain.OutboxEntry
+	for rows.Next() {
+		var entry domain.OutboxEntry
+		var event domain.Event
+
+		err := rows.Scan(
+			&entry.Sequence,
+			&event.ID,
+			&event.TenantID,
+			&event.ItemID,
+			&event.Type,
+			&event.Payload,
+			&event.TraceID,
+			&event.CreatedAt,
+			&entry.Attempts,
+			&entry.NextAttemptAt,
+			pq.Array(&entry.DeliveredSinks),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+
+		entry.Event = &event
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (r *OutboxRepo) Reschedule(ctx context.Context, ids []int64, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `UPDATE outbox SET next_attempt_at = $1 WHERE id = ANY($2) AND sent_at IS NULL`
+	if _, err := r.db.conn(ctx).ExecContext(ctx, query, at, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to reschedule outbox events: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepo) MarkSent(ctx context.Context, id int64) error {
+	query := `
+		UPDATE outbox
+		SET sent_at = NOW(),
+		    attempts = attempts + 1,
+		    last_error = NULL
+		WHERE id = $1
+	`
+	if _, err := r.db.conn(ctx).ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark outbox event sent: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepo) MarkFailed(ctx context.Context, id int64, deliveryErr error, nextAttemptAt time.Time) error {
+	errText := "unknown error"
+	if deliveryErr != nil {
+		errText = deliveryErr.Error()
+	}
+
+	query := `
+		UPDATE outbox
+		SET attempts = attempts + 1,
+		    last_error = $1,
+		    next_attempt_at = $2
+		WHERE id = $3
+	`
+	if _, err := r.db.conn(ctx).ExecContext(ctx, query, errText, nextAttemptAt, id); err != nil {
+		return fmt.Errorf("failed to mark outbox event failed: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepo) RecordDeliveredSinks(ctx context.Context, id int64, sinks []string) error {
+	query := `UPDATE outbox SET delivered_sinks = $1 WHERE id = $2`
+	if _, err := r.db.conn(ctx).ExecContext(ctx, query, pq.Array(sinks), id); err != nil {
+		return fmt.Errorf("failed to record delivered sinks: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepo) Park(ctx context.Context, id int64, deliveryErr error) error {
+	errText := "unknown error"
+	if deliveryErr != nil {
+		errText = deliveryErr.Error()
+	}
+
+	query := `
+		UPDATE outbox
+		SET attempts = attempts + 1,
+		    last_error = $1,
+		    parked_at = NOW()
+		WHERE id = $2
+	`
+	if _, err := r.db.conn(ctx).ExecContext(ctx, query, errText, id); err != nil {
+		return fmt.Errorf("failed to park outbox event: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepo) CountParked(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.conn(ctx).QueryRowContext(ctx, `SELECT COUNT(*) FROM outbox WHERE parked_at IS NOT NULL`).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count parked outbox events: %w", err)
+	}
+	return count, nil
+}
+
+func (r *OutboxRepo) DeleteSentBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM outbox WHERE sent_at IS NOT NULL AND sent_at < $1`
+
+	res, err := r.db.conn(ctx).ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sent outbox events: %w", err)
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/lib/pq"
+)
+
+type ReconciliationRepo struct {
+	db *DB
+}
+
+func NewReconciliationRepo(db *DB) *ReconciliationRepo {
+	return &ReconciliationRepo{db: db}
+}
+
+func (r *ReconciliationRepo) Create(ctx context.Context, report *domain.ReconciliationReport) error {
+	query := `
+		INSERT INTO reconciliation_reports (
+			id, item_id, window_start, window_end,
+			plaid_count, stored_count, missing_count, mismatched_count, phantom_count,
+			phantom_transaction_ids, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+	`
+
+	phantomIDs := report.PhantomTransactionIDs
+	if phantomIDs == nil {
+		phantomIDs = []string{}
+	}
+
+	_, err := r.db.conn(ctx).ExecContext(ctx, query,
+		report.ID,
+		report.ItemID,
+		report.WindowStart,
+		report.WindowEnd,
+		report.PlaidCount,
+		report.StoredCount,
+		report.MissingCount,
+		report.MismatchedCount,
+		report.PhantomCount,
+		pq.Array(phantomIDs),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create reconciliation report: %w", err)
+	}
+
+	return nil
+}
@@ -2,20 +2,26 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/alexchny/sync-relay/pkg/txnotify"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
 type TransactionRepo struct {
 	db *DB
+	// send pg_notify on txnotify.Channel for every write
+	notify bool
 }
 
-func NewTransactionRepo(db *DB) *TransactionRepo {
-	return &TransactionRepo{db: db}
+func NewTransactionRepo(db *DB, notify bool) *TransactionRepo {
+	return &TransactionRepo{db: db, notify: notify}
 }
 
 func (r *TransactionRepo) UpsertBatch(ctx context.Context, txs []*domain.Transaction) error {
@@ -26,15 +32,15 @@ func (r *TransactionRepo) UpsertBatch(ctx context.Context, txs []*domain.Transac
 	values := []interface{}{}
 	placeholders := []string{}
 
-	const paramsPerTx = 9
+	const paramsPerTx = 10
 
 	for i, tx := range txs {
 		base := i * paramsPerTx
 
 		row := fmt.Sprintf(
-			"(gen_random_uuid(), $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, NOW(), NOW())",
+			"(gen_random_uuid(), $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, NOW(), NOW())",
 			base+1, base+2, base+3, base+4, base+5,
-			base+6, base+7, base+8, base+9,
+			base+6, base+7, base+8, base+9, base+10,
 		)
 		placeholders = append(placeholders, row)
 
@@ -42,6 +48,7 @@ func (r *TransactionRepo) UpsertBatch(ctx context.Context, txs []*domain.Transac
 			tx.ItemID,
 			tx.PlaidTransactionID,
 			tx.PlaidPendingID,
+			tx.AccountID,
 			tx.AmountCents,
 			tx.CurrencyCode,
 			tx.Date,
@@ -57,6 +64,7 @@ func (r *TransactionRepo) UpsertBatch(ctx context.Context, txs []*domain.Transac
 			item_id, 
 			plaid_transaction_id, 
 			plaid_pending_id,
+			account_id,
 			amount_cents,
 			currency_code,
 			date,
@@ -73,16 +81,69 @@ func (r *TransactionRepo) UpsertBatch(ctx context.Context, txs []*domain.Transac
 			date = EXCLUDED.date,
 			merchant_name = EXCLUDED.merchant_name,
 			plaid_pending_id = EXCLUDED.plaid_pending_id,
+			account_id = EXCLUDED.account_id,
 			status = EXCLUDED.status,
 			raw_payload = EXCLUDED.raw_payload,
 			is_removed = FALSE,
+			phantom_at = NULL,
 			updated_at = NOW()
 	`, strings.Join(placeholders, ","))
 
+	if r.notify {
+		return r.upsertAndNotify(ctx, query, values)
+	}
+
 	// execute
-	if _, err := r.db.ExecContext(ctx, query, values...); err != nil {
+	if _, err := r.db.conn(ctx).ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("failed to upsert batch: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TransactionRepo) upsertAndNotify(ctx context.Context, query string, values []interface{}) error {
+	// xmax is 0 only for rows the insert created, conflicting rows were updated
+	rows, err := r.db.conn(ctx).QueryContext(ctx, query+` RETURNING item_id, plaid_transaction_id, (xmax = 0)`, values...)
+	if err != nil {
 		return fmt.Errorf("failed to upsert batch: %w", err)
 	}
+	defer func() { _ = rows.Close() }()
+
+	var itemIDs []uuid.UUID
+	added := make(map[uuid.UUID][]string)
+	modified := make(map[uuid.UUID][]string)
+
+	for rows.Next() {
+		var itemID uuid.UUID
+		var plaidTxID string
+		var inserted bool
+		if err := rows.Scan(&itemID, &plaidTxID, &inserted); err != nil {
+			return fmt.Errorf("failed to scan upserted transaction: %w", err)
+		}
+
+		if _, ok := added[itemID]; !ok {
+			if _, ok := modified[itemID]; !ok {
+				itemIDs = append(itemIDs, itemID)
+			}
+		}
+		if inserted {
+			added[itemID] = append(added[itemID], plaidTxID)
+		} else {
+			modified[itemID] = append(modified[itemID], plaidTxID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to upsert batch: %w", err)
+	}
+
+	for _, itemID := range itemIDs {
+		if err := r.notifyChanges(ctx, itemID, txnotify.KindAdded, added[itemID]); err != nil {
+			return err
+		}
+		if err := r.notifyChanges(ctx, itemID, txnotify.KindModified, modified[itemID]); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -98,19 +159,330 @@ func (r *TransactionRepo) MarkRemovedBatch(ctx context.Context, itemID uuid.UUID
 		WHERE item_id = $1 AND plaid_transaction_id = ANY($2)
 	`
 
-	if _, err := r.db.ExecContext(ctx, query, itemID, pq.Array(plaidTxIDs)); err != nil {
+	if !r.notify {
+		if _, err := r.db.conn(ctx).ExecContext(ctx, query, itemID, pq.Array(plaidTxIDs)); err != nil {
+			return fmt.Errorf("failed to mark removed: %w", err)
+		}
+		return nil
+	}
+
+	rows, err := r.db.conn(ctx).QueryContext(ctx, query+` RETURNING plaid_transaction_id`, itemID, pq.Array(plaidTxIDs))
+	if err != nil {
+		return fmt.Errorf("failed to mark removed: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var removed []string
+	for rows.Next() {
+		var plaidTxID string
+		if err := rows.Scan(&plaidTxID); err != nil {
+			return fmt.Errorf("failed to scan removed transaction: %w", err)
+		}
+		removed = append(removed, plaidTxID)
+	}
+	if err := rows.Err(); err != nil {
 		return fmt.Errorf("failed to mark removed: %w", err)
 	}
 
+	return r.notifyChanges(ctx, itemID, txnotify.KindRemoved, removed)
+}
+
+// notifyChanges queues notifications that postgres sends when the current
+// transaction commits, split so each payload stays under the notify limit.
+func (r *TransactionRepo) notifyChanges(ctx context.Context, itemID uuid.UUID, kind txnotify.Kind, plaidTxIDs []string) error {
+	payloads, err := txnotify.Payloads(itemID, kind, plaidTxIDs)
+	if err != nil {
+		return fmt.Errorf("failed to encode change notification: %w", err)
+	}
+
+	for _, payload := range payloads {
+		if _, err := r.db.conn(ctx).ExecContext(ctx, `SELECT pg_notify($1, $2)`, txnotify.Channel, payload); err != nil {
+			return fmt.Errorf("failed to send change notification: %w", err)
+		}
+	}
+
 	return nil
 }
 
 func (r *TransactionRepo) DeleteAllForItem(ctx context.Context, itemID uuid.UUID) error {
 	query := `DELETE FROM transactions WHERE item_id = $1`
 
-	if _, err := r.db.ExecContext(ctx, query, itemID); err != nil {
+	if _, err := r.db.conn(ctx).ExecContext(ctx, query, itemID); err != nil {
 		return fmt.Errorf("failed to delete all transactions for item %s: %w", itemID, err)
 	}
 
 	return nil
 }
+
+func (r *TransactionRepo) GetByPlaidIDs(ctx context.Context, itemID uuid.UUID, plaidTxIDs []string) ([]*domain.Transaction, error) {
+	if len(plaidTxIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT ` + transactionColumns + `
+		FROM transactions t
+		WHERE t.item_id = $1 AND t.plaid_transaction_id = ANY($2)
+	`
+
+	rows, err := r.db.conn(ctx).QueryContext(ctx, query, itemID, pq.Array(plaidTxIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	txs := make([]*domain.Transaction, 0, len(plaidTxIDs))
+	for rows.Next() {
+		tx, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+
+	return txs, rows.Err()
+}
+
+func (r *TransactionRepo) ListByDateRange(ctx context.Context, itemID uuid.UUID, start, end time.Time) ([]*domain.Transaction, error) {
+	query := `SELECT ` + transactionColumns + `
+		FROM transactions t
+		WHERE t.item_id = $1 AND t.date BETWEEN $2 AND $3
+	`
+
+	rows, err := r.db.conn(ctx).QueryContext(ctx, query, itemID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var txs []*domain.Transaction
+	for rows.Next() {
+		tx, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+
+	return txs, rows.Err()
+}
+
+func (r *TransactionRepo) SetPhantoms(ctx context.Context, itemID uuid.UUID, start, end time.Time, plaidTxIDs []string) error {
+	// a row keeps the time it was first found missing
+	query := `
+		UPDATE transactions
+		SET phantom_at = CASE
+			WHEN plaid_transaction_id = ANY($4) THEN COALESCE(phantom_at, NOW())
+			ELSE NULL
+		END
+		WHERE item_id = $1 AND date BETWEEN $2 AND $3
+			AND (phantom_at IS NOT NULL OR plaid_transaction_id = ANY($4))
+	`
+
+	if plaidTxIDs == nil {
+		plaidTxIDs = []string{}
+	}
+	if _, err := r.db.conn(ctx).ExecContext(ctx, query, itemID, start, end, pq.Array(plaidTxIDs)); err != nil {
+		return fmt.Errorf("failed to flag phantom transactions: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TransactionRepo) List(ctx context.Context, filter ports.TransactionFilter) ([]*domain.Transaction, error) {
+	// removed items are on their way to being purged, hide them already
+	conds := []string{"i.tenant_id = $1", "i.sync_status <> 'removed'"}
+	args := []interface{}{filter.TenantID}
+
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.ItemID != nil {
+		add("t.item_id = $%d", *filter.ItemID)
+	}
+	if filter.AccountID != "" {
+		add("t.account_id = $%d", filter.AccountID)
+	}
+	if filter.StartDate != nil {
+		add("t.date >= $%d", *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		add("t.date <= $%d", *filter.EndDate)
+	}
+	if filter.Status != "" {
+		add("t.status = $%d", filter.Status)
+	}
+	if filter.MinAmountCents != nil {
+		add("t.amount_cents >= $%d", *filter.MinAmountCents)
+	}
+	if filter.MaxAmountCents != nil {
+		add("t.amount_cents <= $%d", *filter.MaxAmountCents)
+	}
+	if filter.Merchant != "" {
+		add(`t.merchant_name ILIKE $%d ESCAPE '\'`, "%"+escapeLike(filter.Merchant)+"%")
+	}
+	if !filter.IncludeRemoved {
+		conds = append(conds, "t.is_removed IS NOT TRUE")
+	}
+	if filter.Phantom != nil {
+		if *filter.Phantom {
+			conds = append(conds, "t.phantom_at IS NOT NULL")
+		} else {
+			conds = append(conds, "t.phantom_at IS NULL")
+		}
+	}
+	if filter.After != nil {
+		args = append(args, filter.After.Date, filter.After.ID)
+		conds = append(conds, fmt.Sprintf("(t.date, t.id) < ($%d::date, $%d::uuid)", len(args)-1, len(args)))
+	}
+
+	args = append(args, filter.Limit)
+	query := `SELECT ` + transactionColumns + `
+		FROM transactions t
+		JOIN items i ON i.id = t.item_id
+		WHERE ` + strings.Join(conds, " AND ") + `
+		ORDER BY t.date DESC, t.id DESC
+		LIMIT $` + fmt.Sprint(len(args))
+
+	rows, err := r.db.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	txs := make([]*domain.Transaction, 0, filter.Limit)
+	for rows.Next() {
+		tx, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+
+	return txs, rows.Err()
+}
+
+func (r *TransactionRepo) CountByItems(ctx context.Context, itemIDs []uuid.UUID) (map[uuid.UUID]ports.TransactionCounts, error) {
+	counts := make(map[uuid.UUID]ports.TransactionCounts, len(itemIDs))
+	if len(itemIDs) == 0 {
+		return counts, nil
+	}
+
+	ids := make([]string, 0, len(itemIDs))
+	for _, id := range itemIDs {
+		ids = append(ids, id.String())
+	}
+
+	query := `
+		SELECT
+			item_id,
+			COUNT(*) FILTER (WHERE is_removed IS NOT TRUE AND status = 'pending'),
+			COUNT(*) FILTER (WHERE is_removed IS NOT TRUE AND status = 'posted'),
+			COUNT(*) FILTER (WHERE is_removed IS TRUE)
+		FROM transactions
+		WHERE item_id = ANY($1::uuid[])
+		GROUP BY item_id
+	`
+
+	rows, err := r.db.conn(ctx).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count transactions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var itemID uuid.UUID
+		var c ports.TransactionCounts
+		if err := rows.Scan(&itemID, &c.Pending, &c.Posted, &c.Removed); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction counts: %w", err)
+		}
+		counts[itemID] = c
+	}
+
+	return counts, rows.Err()
+}
+
+// escapeLike makes user input match literally inside a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func (r *TransactionRepo) ListActivePlaidIDs(ctx context.Context, itemID uuid.UUID) ([]string, error) {
+	query := `
+		SELECT plaid_transaction_id
+		FROM transactions
+		WHERE item_id = $1 AND is_removed IS NOT TRUE
+	`
+
+	rows, err := r.db.conn(ctx).QueryContext(ctx, query, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transaction ids: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+const transactionColumns = `
+	t.id, t.item_id, t.plaid_transaction_id, t.plaid_pending_id, t.account_id,
+	t.amount_cents, t.currency_code, t.date, t.merchant_name, t.status,
+	t.raw_payload, COALESCE(t.is_removed, FALSE), t.phantom_at, t.created_at, t.updated_at
+`
+
+func scanTransaction(rows *sql.Rows) (*domain.Transaction, error) {
+	var tx domain.Transaction
+	var pendingID sql.NullString
+	var accountID sql.NullString
+	var merchantName sql.NullString
+	var phantomAt sql.NullTime
+
+	err := rows.Scan(
+		&tx.ID,
+		&tx.ItemID,
+		&tx.PlaidTransactionID,
+		&pendingID,
+		&accountID,
+		&tx.AmountCents,
+		&tx.CurrencyCode,
+		&tx.Date,
+		&merchantName,
+		&tx.Status,
+		&tx.RawPayload,
+		&tx.IsRemoved,
+		&phantomAt,
+		&tx.CreatedAt,
+		&tx.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan transaction: %w", err)
+	}
+
+	if pendingID.Valid {
+		id := pendingID.String
+		tx.PlaidPendingID = &id
+	}
+
+	if accountID.Valid {
+		tx.AccountID = accountID.String
+	}
+
+	if merchantName.Valid {
+		tx.MerchantName = merchantName.String
+	}
+
+	if phantomAt.Valid {
+		tx.PhantomAt = &phantomAt.Time
+	}
+
+	return &tx, nil
+}
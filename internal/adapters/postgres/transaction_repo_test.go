@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+)
+
+func TestTransactionRepoPhantoms(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	item := createTestItem(t, NewItemRepo(db))
+	repo := NewTransactionRepo(db, false)
+
+	date := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	var txs []*domain.Transaction
+	for _, id := range []string{"tx-kept", "tx-phantom"} {
+		txs = append(txs, &domain.Transaction{
+			ItemID:             item.ID,
+			PlaidTransactionID: id,
+			AmountCents:        100,
+			CurrencyCode:       "USD",
+			Date:               date,
+			Status:             domain.TransactionStatusPosted,
+		})
+	}
+	if err := repo.UpsertBatch(ctx, txs); err != nil {
+		t.Fatal(err)
+	}
+
+	start, end := date.AddDate(0, 0, -1), date.AddDate(0, 0, 1)
+	if err := repo.SetPhantoms(ctx, item.ID, start, end, []string{"tx-phantom"}); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(phantom bool) []string {
+		t.Helper()
+		got, err := repo.List(ctx, ports.TransactionFilter{TenantID: item.TenantID, Phantom: &phantom, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, tx := range got {
+			ids = append(ids, tx.PlaidTransactionID)
+		}
+		return ids
+	}
+
+	if got := list(true); !slices.Equal(got, []string{"tx-phantom"}) {
+		t.Fatalf("phantoms = %v, want [tx-phantom]", got)
+	}
+	if got := list(false); !slices.Equal(got, []string{"tx-kept"}) {
+		t.Fatalf("non-phantoms = %v, want [tx-kept]", got)
+	}
+
+	// plaid returns the row again
+	if err := repo.SetPhantoms(ctx, item.ID, start, end, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(true); len(got) != 0 {
+		t.Fatalf("phantoms = %v after the row came back, want none", got)
+	}
+}
@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type WebhookSubscriptionRepo struct {
+	db *DB
+}
+
+func NewWebhookSubscriptionRepo(db *DB) *WebhookSubscriptionRepo {
+	return &WebhookSubscriptionRepo{db: db}
+}
+
+func scanSubscription(row scanner) (*domain.WebhookSubscription, error) {
+	var sub domain.WebhookSubscription
+	var eventTypes []string
+
+	err := row.Scan(
+		&sub.ID,
+		&sub.TenantID,
+		&sub.URL,
+		pq.Array(&eventTypes),
+		&sub.Secret,
+		&sub.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.EventTypes = make([]domain.EventType, 0, len(eventTypes))
+	for _, t := range eventTypes {
+		sub.EventTypes = append(sub.EventTypes, domain.EventType(t))
+	}
+
+	return &sub, nil
+}
+
+func (r *WebhookSubscriptionRepo) Create(ctx context.Context, sub *domain.WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (id, tenant_id, url, event_types, secret, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING created_at
+	`
+
+	eventTypes := make([]string, 0, len(sub.EventTypes))
+	for _, t := range sub.EventTypes {
+		eventTypes = append(eventTypes, string(t))
+	}
+
+	err := r.db.conn(ctx).QueryRowContext(ctx, query,
+		sub.ID,
+		sub.TenantID,
+		sub.URL,
+		pq.Array(eventTypes),
+		sub.Secret,
+	).Scan(&sub.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (r *WebhookSubscriptionRepo) GetByID(ctx context.Context, tenantID, id uuid.UUID) (*domain.WebhookSubscription, error) {
+	query := `
+		SELECT id, tenant_id, url, event_types, secret, created_at
+		FROM webhook_subscriptions
+		WHERE tenant_id = $1 AND id = $2
+	`
+
+	sub, err := scanSubscription(r.db.conn(ctx).QueryRowContext(ctx, query, tenantID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ports.ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+
+	return sub, nil
+}
+
+func (r *WebhookSubscriptionRepo) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*domain.WebhookSubscription, error) {
+	query := `
+		SELECT id, tenant_id, url, event_types, secret, created_at
+		FROM webhook_subscriptions
+		WHERE tenant_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.conn(ctx).QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscriptions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var subs []*domain.WebhookSubscription
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook subscriptions: %w", err)
+	}
+
+	return subs, nil
+}
+
+func (r *WebhookSubscriptionRepo) Delete(ctx context.Context, tenantID, id uuid.UUID) error {
+	query := `DELETE FROM webhook_subscriptions WHERE tenant_id = $1 AND id = $2`
+
+	result, err := r.db.conn(ctx).ExecContext(ctx, query, tenantID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ports.ErrSubscriptionNotFound
+	}
+
+	return nil
+}
+
+type WebhookDeliveryRepo struct {
+	db *DB
+}
+
+func NewWebhookDeliveryRepo(db *DB) *WebhookDeliveryRepo {
+	return &WebhookDeliveryRepo{db: db}
+}
+
+func (r *WebhookDeliveryRepo) Record(ctx context.Context, d *domain.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (
+			id, subscription_id, event_id, attempt, status_code, error, duration_ms, delivered, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+	`
+
+	var statusCode sql.NullInt64
+	if d.StatusCode != 0 {
+		statusCode = sql.NullInt64{Int64: int64(d.StatusCode), Valid: true}
+	}
+
+	var deliveryErr sql.NullString
+	if d.Error != "" {
+		deliveryErr = sql.NullString{String: d.Error, Valid: true}
+	}
+
+	_, err := r.db.conn(ctx).ExecContext(ctx, query,
+		d.ID,
+		d.SubscriptionID,
+		d.EventID,
+		d.Attempt,
+		statusCode,
+		deliveryErr,
+		d.Duration.Milliseconds(),
+		d.Delivered,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (r *WebhookDeliveryRepo) Status(ctx context.Context, subscriptionID, eventID uuid.UUID) (int, bool, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(BOOL_OR(delivered), FALSE)
+		FROM webhook_deliveries
+		WHERE subscription_id = $1 AND event_id = $2
+	`
+
+	var attempts int
+	var delivered bool
+	if err := r.db.conn(ctx).QueryRowContext(ctx, query, subscriptionID, eventID).Scan(&attempts, &delivered); err != nil {
+		return 0, false, fmt.Errorf("failed to get webhook delivery status: %w", err)
+	}
+
+	return attempts, delivered, nil
+}
+
+func (r *WebhookDeliveryRepo) ListBySubscription(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*domain.WebhookDelivery, error) {
+	query := `
+		SELECT id, subscription_id, event_id, attempt, status_code, error, duration_ms, delivered, created_at
+		FROM webhook_deliveries
+		WHERE subscription_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2
+	`
+
+	rows, err := r.db.conn(ctx).QueryContext(ctx, query, subscriptionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var deliveries []*domain.WebhookDelivery
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		var statusCode sql.NullInt64
+		var deliveryErr sql.NullString
+		var durationMs int64
+
+		err := rows.Scan(
+			&d.ID,
+			&d.SubscriptionID,
+			&d.EventID,
+			&d.Attempt,
+			&statusCode,
+			&deliveryErr,
+			&durationMs,
+			&d.Delivered,
+			&d.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+
+		d.StatusCode = int(statusCode.Int64)
+		d.Error = deliveryErr.String
+		d.Duration = time.Duration(durationMs) * time.Millisecond
+
+		deliveries = append(deliveries, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
@@ -2,14 +2,14 @@ package redis
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"time"
 
+	"github.com/alexchny/sync-relay/internal/ports"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 )
 
-var ErrLockBusy = errors.New("lock is already acquired")
-
 type LockAdapter struct {
 	client *Client
 }
@@ -18,32 +18,97 @@ func NewLockAdapter(client *Client) *LockAdapter {
 	return &LockAdapter{client: client}
 }
 
-func (l *LockAdapter) Acquire(ctx context.Context, key string, ttl time.Duration) (func() error, error) {
+func (l *LockAdapter) Acquire(ctx context.Context, key string, ttl time.Duration) (ports.Lock, error) {
+	// take the lock and bump the fencing counter in one step, the counter
+	// key never expires so tokens keep growing across holders
+	const script = `
+		if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+			return redis.call("incr", KEYS[2])
+		end
+		return 0
+	`
+
 	token := uuid.NewString()
 
-	success, err := l.client.rdb.SetNX(ctx, key, token, ttl).Result()
+	fence, err := l.client.rdb.Eval(ctx, script, []string{key, key + ":fence"}, token, ttl.Milliseconds()).Int64()
 	if err != nil {
 		return nil, err
 	}
-	if !success {
-		return nil, ErrLockBusy
+	if fence == 0 {
+		return nil, ports.ErrLockBusy
 	}
 
-	release := func() error {
-		const script = `
-			if redis.call("get", KEYS[1]) == ARGV[1] then
-				return redis.call("del", KEYS[1])
-			else
-				return 0
-			end
-		`
+	return &redisLock{
+		client: l.client,
+		key:    key,
+		token:  token,
+		ttl:    ttl,
+		fence:  fence,
+	}, nil
+}
 
-		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+func (l *LockAdapter) Held(ctx context.Context, keys ...string) (map[string]bool, error) {
+	pipe := l.client.rdb.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Exists(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("redis exists failed: %w", err)
+	}
 
-		err := l.client.rdb.Eval(releaseCtx, script, []string{key}, token).Err()
+	held := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		held[key] = cmds[i].Val() > 0
+	}
+
+	return held, nil
+}
+
+type redisLock struct {
+	client *Client
+	key    string
+	token  string
+	ttl    time.Duration
+	fence  int64
+}
+
+func (l *redisLock) FencingToken() int64 {
+	return l.fence
+}
+
+func (l *redisLock) Refresh(ctx context.Context) error {
+	const script = `
+		if redis.call("get", KEYS[1]) == ARGV[1] then
+			return redis.call("pexpire", KEYS[1], ARGV[2])
+		else
+			return 0
+		end
+	`
+
+	ok, err := l.client.rdb.Eval(ctx, script, []string{l.key}, l.token, l.ttl.Milliseconds()).Int()
+	if err != nil {
 		return err
 	}
+	if ok == 0 {
+		return ports.ErrLockLost
+	}
+
+	return nil
+}
+
+func (l *redisLock) Release() error {
+	const script = `
+		if redis.call("get", KEYS[1]) == ARGV[1] then
+			return redis.call("del", KEYS[1])
+		else
+			return 0
+		end
+	`
+
+	releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	return release, nil
+	err := l.client.rdb.Eval(releaseCtx, script, []string{l.key}, l.token).Err()
+	return err
 }
@@ -0,0 +1,40 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexchny/sync-relay/internal/cloudevents"
+	"github.com/alexchny/sync-relay/internal/domain"
+)
+
+// pub/sub messages have no headers, so events always go out as structured
+// cloudevents regardless of the configured content mode
+type PubSubPublisher struct {
+	client  *Client
+	channel string
+	source  string
+}
+
+func NewPubSubPublisher(client *Client, channel, source string) *PubSubPublisher {
+	return &PubSubPublisher{
+		client:  client,
+		channel: channel,
+		source:  source,
+	}
+}
+
+func (p *PubSubPublisher) Publish(ctx context.Context, events ...*domain.Event) error {
+	for _, e := range events {
+		msg, err := cloudevents.Encode(cloudevents.FromEvent(e, p.source), cloudevents.ModeStructured)
+		if err != nil {
+			return err
+		}
+
+		if err := p.client.rdb.Publish(ctx, p.channel, msg.Body).Err(); err != nil {
+			return fmt.Errorf("redis publish failed: %w", err)
+		}
+	}
+
+	return nil
+}
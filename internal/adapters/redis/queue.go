@@ -2,9 +2,13 @@ package redis
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/alexchny/sync-relay/internal/domain"
@@ -12,55 +16,417 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// consumers refresh their heartbeat well inside this window, a consumer
+// whose heartbeat has expired is considered dead and its jobs are requeued
+const heartbeatTTL = 30 * time.Second
+
+var ErrJobNotInFlight = errors.New("job is not in flight")
+
+var ErrJobNotDead = errors.New("job is not in the dead-letter queue")
+
+// undecodable payloads are dead-lettered as they are, there is no job to replay
+var ErrJobUndecodable = errors.New("dead-lettered payload is not a decodable job")
+
+type inFlightJob struct {
+	consumerID string
+	payload    string
+}
+
 type QueueAdapter struct {
 	client   *Client
 	queueKey string
+
+	mu       sync.Mutex
+	inFlight map[string]inFlightJob
 }
 
 func NewQueueAdapter(client *Client, queueKey string) *QueueAdapter {
 	return &QueueAdapter{
 		client:   client,
 		queueKey: queueKey,
+		inFlight: make(map[string]inFlightJob),
 	}
 }
 
-func (q *QueueAdapter) Dequeue(ctx context.Context, timeout time.Duration) (*domain.SyncJob, error) {
-	result, err := q.client.rdb.BLPop(ctx, timeout, q.queueKey).Result()
+func (q *QueueAdapter) processingKey(consumerID string) string {
+	return fmt.Sprintf("%s:processing:%s", q.queueKey, consumerID)
+}
+
+func (q *QueueAdapter) heartbeatKey(consumerID string) string {
+	return fmt.Sprintf("%s:heartbeat:%s", q.queueKey, consumerID)
+}
+
+func (q *QueueAdapter) consumersKey() string {
+	return q.queueKey + ":consumers"
+}
+
+// per-item count of jobs between enqueue and ack or dead-letter, so status
+// lookups don't have to scan the queue
+func (q *QueueAdapter) pendingKey() string {
+	return q.queueKey + ":pending"
+}
+
+// KEYS[1] = processing list, KEYS[2] = pending counts, KEYS[3] = dead list,
+// ARGV[1] = payload, ARGV[2] = item id, ARGV[3] = dead payload or empty to drop the job
+const settleScript = `
+	if redis.call("lrem", KEYS[1], 1, ARGV[1]) == 0 then
+		return 0
+	end
+	if ARGV[3] ~= "" then
+		redis.call("rpush", KEYS[3], ARGV[3])
+	end
+	if redis.call("hincrby", KEYS[2], ARGV[2], -1) <= 0 then
+		redis.call("hdel", KEYS[2], ARGV[2])
+	end
+	return 1
+`
+
+// KEYS[1] = processing list, KEYS[2] = queue, KEYS[3] = delayed set,
+// ARGV[1] = payload, ARGV[2] = requeued payload, ARGV[3] = ready time in ms or empty to requeue now
+const nackScript = `
+	if redis.call("lrem", KEYS[1], 1, ARGV[1]) == 0 then
+		return 0
+	end
+	if ARGV[3] ~= "" then
+		redis.call("zadd", KEYS[3], ARGV[3], ARGV[2])
+	else
+		redis.call("lpush", KEYS[2], ARGV[2])
+	end
+	return 1
+`
+
+func (q *QueueAdapter) Dequeue(ctx context.Context, consumerID string, timeout time.Duration) (*domain.SyncJob, error) {
+	// register before taking work so the reaper can always find our list
+	if err := q.Heartbeat(ctx, consumerID); err != nil {
+		return nil, err
+	}
+
+	processingKey := q.processingKey(consumerID)
+
+	payload, err := q.client.rdb.BLMove(ctx, q.queueKey, processingKey, "LEFT", "RIGHT", timeout).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("redis blpop failed: %w", err)
+		return nil, fmt.Errorf("redis blmove failed: %w", err)
 	}
 
 	var job domain.SyncJob
-	if err := json.Unmarshal([]byte(result[1]), &job); err != nil {
+	if err := json.Unmarshal([]byte(payload), &job); err != nil {
+		// poison message, don't let it be redelivered forever. the raw payload
+		// is dead-lettered so it can still be inspected. the item id is
+		// whatever could be decoded, a nil id only touches a field that the
+		// script deletes again
+		keys := []string{processingKey, q.pendingKey(), q.deadLetterKey()}
+		if settleErr := q.client.rdb.Eval(ctx, settleScript, keys, payload, job.ItemID.String(), payload).Err(); settleErr != nil {
+			return nil, fmt.Errorf("failed to dead-letter undecodable job: %w", settleErr)
+		}
 		return nil, fmt.Errorf("failed to unmarshal job: %w", err)
 	}
 
+	// jobs queued before ids existed would all share the "" slot in inFlight.
+	// the raw payload is kept, it's what identifies the entry in the list
+	if job.ID == "" {
+		job.ID = uuid.NewString()
+	}
+
+	q.mu.Lock()
+	q.inFlight[job.ID] = inFlightJob{consumerID: consumerID, payload: payload}
+	q.mu.Unlock()
+
 	return &job, nil
 }
 
 func (q *QueueAdapter) Enqueue(ctx context.Context, job *domain.SyncJob) error {
+	if job.ID == "" {
+		job.ID = uuid.NewString()
+	}
+
 	data, err := json.Marshal(job)
 	if err != nil {
 		return err
 	}
-	return q.client.rdb.RPush(ctx, q.queueKey, data).Err()
+
+	_, err = q.client.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, q.queueKey, data)
+		pipe.HIncrBy(ctx, q.pendingKey(), job.ItemID.String(), 1)
+		return nil
+	})
+	return err
 }
 
-func (q *QueueAdapter) PublishSyncEvents(ctx context.Context, itemID uuid.UUID, added, modified []*domain.Transaction, removed []string) error {
-	event := map[string]interface{}{
-		"type":      "SYNC_UPDATES",
-		"item_id":   itemID,
-		"counts":    map[string]int{"added": len(added), "modified": len(modified), "removed": len(removed)},
-		"timestamp": time.Now(),
+func (q *QueueAdapter) Ack(ctx context.Context, job *domain.SyncJob) error {
+	entry, err := q.takeInFlight(job)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{q.processingKey(entry.consumerID), q.pendingKey(), q.deadLetterKey()}
+	if err := q.client.rdb.Eval(ctx, settleScript, keys, entry.payload, job.ItemID.String(), "").Err(); err != nil {
+		return fmt.Errorf("redis ack failed: %w", err)
 	}
 
-	data, err := json.Marshal(event)
+	return nil
+}
+
+func (q *QueueAdapter) delayedKey() string {
+	return q.queueKey + ":delayed"
+}
+
+func (q *QueueAdapter) deadLetterKey() string {
+	return q.queueKey + ":dead"
+}
+
+func (q *QueueAdapter) Nack(ctx context.Context, job *domain.SyncJob, delay time.Duration) error {
+	entry, err := q.takeInFlight(job)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	// remove from in-flight and requeue atomically. if the reaper already
+	// requeued the job the list no longer holds it and there is nothing to do
+	keys := []string{q.processingKey(entry.consumerID), q.queueKey, q.delayedKey()}
+	readyAt := ""
+	if delay > 0 {
+		readyAt = strconv.FormatInt(time.Now().Add(delay).UnixMilli(), 10)
+	}
+	if err := q.client.rdb.Eval(ctx, nackScript, keys, entry.payload, data, readyAt).Err(); err != nil {
+		return fmt.Errorf("redis requeue failed: %w", err)
+	}
+
+	return nil
+}
+
+func (q *QueueAdapter) DeadLetter(ctx context.Context, job *domain.SyncJob) error {
+	entry, err := q.takeInFlight(job)
 	if err != nil {
 		return err
 	}
 
-	return q.client.rdb.Publish(ctx, "sync-events", data).Err()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{q.processingKey(entry.consumerID), q.pendingKey(), q.deadLetterKey()}
+	err = q.client.rdb.Eval(ctx, settleScript, keys, entry.payload, job.ItemID.String(), data).Err()
+	if err != nil {
+		return fmt.Errorf("redis dead-letter failed: %w", err)
+	}
+
+	return nil
+}
+
+// PromoteDue moves delayed jobs whose backoff has elapsed onto the queue.
+func (q *QueueAdapter) PromoteDue(ctx context.Context) (int, error) {
+	// KEYS[1] = delayed set, KEYS[2] = queue, ARGV[1] = now in ms, ARGV[2] = batch size
+	const script = `
+		local due = redis.call("zrangebyscore", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+		for _, payload in ipairs(due) do
+			redis.call("zrem", KEYS[1], payload)
+			redis.call("rpush", KEYS[2], payload)
+		end
+		return #due
+	`
+
+	keys := []string{q.delayedKey(), q.queueKey}
+	promoted, err := q.client.rdb.Eval(ctx, script, keys, time.Now().UnixMilli(), 100).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to promote delayed jobs: %w", err)
+	}
+
+	return promoted, nil
+}
+
+func (q *QueueAdapter) ListDeadLetters(ctx context.Context, limit int64) ([]*domain.SyncJob, error) {
+	// limit <= 0 lists everything
+	stop := int64(-1)
+	if limit > 0 {
+		stop = limit - 1
+	}
+
+	payloads, err := q.client.rdb.LRange(ctx, q.deadLetterKey(), 0, stop).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis lrange failed: %w", err)
+	}
+
+	jobs := make([]*domain.SyncJob, 0, len(payloads))
+	for _, payload := range payloads {
+		jobs = append(jobs, decodeDeadLetter(payload))
+	}
+
+	return jobs, nil
+}
+
+func (q *QueueAdapter) QueuedItems(ctx context.Context, itemIDs ...uuid.UUID) (map[uuid.UUID]struct{}, error) {
+	items := make(map[uuid.UUID]struct{})
+	if len(itemIDs) == 0 {
+		return items, nil
+	}
+
+	fields := make([]string, len(itemIDs))
+	for i, id := range itemIDs {
+		fields[i] = id.String()
+	}
+
+	counts, err := q.client.rdb.HMGet(ctx, q.pendingKey(), fields...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis hmget failed: %w", err)
+	}
+
+	for i, count := range counts {
+		// missing fields come back nil, anything unparsable is treated as none
+		n, ok := count.(string)
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseInt(n, 10, 64); err == nil && v > 0 {
+			items[itemIDs[i]] = struct{}{}
+		}
+	}
+
+	return items, nil
+}
+
+// ReplayDeadLetter puts a dead job back on the queue with a fresh attempt budget.
+func (q *QueueAdapter) ReplayDeadLetter(ctx context.Context, jobID string) error {
+	// KEYS[1] = dead list, KEYS[2] = queue, KEYS[3] = pending counts,
+	// ARGV[1] = dead payload, ARGV[2] = replay payload, ARGV[3] = item id
+	const script = `
+		if redis.call("lrem", KEYS[1], 1, ARGV[1]) == 1 then
+			redis.call("rpush", KEYS[2], ARGV[2])
+			redis.call("hincrby", KEYS[3], ARGV[3], 1)
+			return 1
+		end
+		return 0
+	`
+
+	payloads, err := q.client.rdb.LRange(ctx, q.deadLetterKey(), 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("redis lrange failed: %w", err)
+	}
+
+	for _, payload := range payloads {
+		var job domain.SyncJob
+		if err := json.Unmarshal([]byte(payload), &job); err != nil {
+			if undecodableJobID(payload) == jobID {
+				return fmt.Errorf("%w: %s", ErrJobUndecodable, jobID)
+			}
+			continue
+		}
+		if job.ID != jobID {
+			continue
+		}
+
+		job.Attempts = 0
+		job.LastError = ""
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+
+		keys := []string{q.deadLetterKey(), q.queueKey, q.pendingKey()}
+		replayed, err := q.client.rdb.Eval(ctx, script, keys, payload, data, job.ItemID.String()).Int()
+		if err != nil {
+			return fmt.Errorf("failed to replay job %s: %w", jobID, err)
+		}
+		if replayed == 0 {
+			return fmt.Errorf("%w: %s", ErrJobNotDead, jobID)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrJobNotDead, jobID)
+}
+
+// decodeDeadLetter lists an undecodable payload as a job named after its
+// content, with the raw payload as the error
+func decodeDeadLetter(payload string) *domain.SyncJob {
+	var job domain.SyncJob
+	if err := json.Unmarshal([]byte(payload), &job); err == nil {
+		return &job
+	}
+
+	raw := payload
+	if len(raw) > 200 {
+		raw = raw[:200] + "..."
+	}
+	return &domain.SyncJob{
+		ID:        undecodableJobID(payload),
+		LastError: "undecodable payload: " + raw,
+	}
+}
+
+func undecodableJobID(payload string) string {
+	sum := sha256.Sum256([]byte(payload))
+	return "undecodable-" + hex.EncodeToString(sum[:8])
+}
+
+func (q *QueueAdapter) takeInFlight(job *domain.SyncJob) (inFlightJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, ok := q.inFlight[job.ID]
+	if !ok {
+		return inFlightJob{}, fmt.Errorf("%w: %s", ErrJobNotInFlight, job.ID)
+	}
+	delete(q.inFlight, job.ID)
+
+	return entry, nil
+}
+
+func (q *QueueAdapter) Heartbeat(ctx context.Context, consumerIDs ...string) error {
+	pipe := q.client.rdb.Pipeline()
+	for _, id := range consumerIDs {
+		pipe.SAdd(ctx, q.consumersKey(), id)
+		pipe.Set(ctx, q.heartbeatKey(id), time.Now().Unix(), heartbeatTTL)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis heartbeat failed: %w", err)
+	}
+
+	return nil
+}
+
+// RequeueOrphaned hands the in-flight jobs of every consumer whose heartbeat
+// has expired back to the queue, returning how many jobs were recovered.
+func (q *QueueAdapter) RequeueOrphaned(ctx context.Context) (int, error) {
+	// KEYS[1] = heartbeat, KEYS[2] = processing list, KEYS[3] = queue, KEYS[4] = consumers
+	const script = `
+		if redis.call("exists", KEYS[1]) == 1 then
+			return -1
+		end
+		local moved = 0
+		while redis.call("lmove", KEYS[2], KEYS[3], "RIGHT", "LEFT") do
+			moved = moved + 1
+		end
+		redis.call("srem", KEYS[4], ARGV[1])
+		return moved
+	`
+
+	consumers, err := q.client.rdb.SMembers(ctx, q.consumersKey()).Result()
+	if err != nil {
+		return 0, fmt.Errorf("redis smembers failed: %w", err)
+	}
+
+	recovered := 0
+	for _, id := range consumers {
+		keys := []string{q.heartbeatKey(id), q.processingKey(id), q.queueKey, q.consumersKey()}
+
+		moved, err := q.client.rdb.Eval(ctx, script, keys, id).Int()
+		if err != nil {
+			return recovered, fmt.Errorf("failed to requeue jobs for consumer %s: %w", id, err)
+		}
+		if moved > 0 {
+			recovered += moved
+		}
+	}
+
+	return recovered, nil
 }
@@ -0,0 +1,299 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/google/uuid"
+)
+
+// the queue's guarantees live in lua scripts, so these run against a real
+// redis: TEST_REDIS_ADDR=localhost:6379 go test ./internal/adapters/redis
+func newTestQueue(t *testing.T) *QueueAdapter {
+	t.Helper()
+
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR not set")
+	}
+
+	client, err := NewClient(addr, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a fresh key space per test, removed afterwards
+	q := NewQueueAdapter(client, "test-queue:"+uuid.NewString())
+	t.Cleanup(func() {
+		ctx := context.Background()
+		keys, _ := client.rdb.Keys(ctx, q.queueKey+"*").Result()
+		if len(keys) > 0 {
+			_ = client.rdb.Del(ctx, keys...).Err()
+		}
+		_ = client.Close()
+	})
+
+	return q
+}
+
+func enqueue(t *testing.T, q *QueueAdapter) *domain.SyncJob {
+	t.Helper()
+
+	job := &domain.SyncJob{ItemID: uuid.New()}
+	if err := q.Enqueue(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func dequeue(t *testing.T, q *QueueAdapter, consumerID string) *domain.SyncJob {
+	t.Helper()
+
+	job, err := q.Dequeue(context.Background(), consumerID, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil {
+		t.Fatal("queue is empty")
+	}
+	return job
+}
+
+func assertLen(t *testing.T, q *QueueAdapter, key string, want int64) {
+	t.Helper()
+
+	n, err := q.client.rdb.LLen(context.Background(), key).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != want {
+		t.Fatalf("len(%s) = %d, want %d", key, n, want)
+	}
+}
+
+func assertQueued(t *testing.T, q *QueueAdapter, itemID uuid.UUID, want bool) {
+	t.Helper()
+
+	items, err := q.QueuedItems(context.Background(), itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := items[itemID]; ok != want {
+		t.Fatalf("item queued = %v, want %v", ok, want)
+	}
+}
+
+func TestQueueAckSettlesJob(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	job := enqueue(t, q)
+	assertQueued(t, q, job.ItemID, true)
+
+	got := dequeue(t, q, "worker-1")
+	if got.ID != job.ID {
+		t.Fatalf("dequeued %s, want %s", got.ID, job.ID)
+	}
+	assertLen(t, q, q.processingKey("worker-1"), 1)
+
+	if err := q.Ack(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	assertLen(t, q, q.processingKey("worker-1"), 0)
+	assertQueued(t, q, job.ItemID, false)
+
+	if err := q.Ack(ctx, got); !errors.Is(err, ErrJobNotInFlight) {
+		t.Fatalf("second ack = %v, want ErrJobNotInFlight", err)
+	}
+}
+
+func TestQueueNackRequeues(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	job := enqueue(t, q)
+	got := dequeue(t, q, "worker-1")
+	got.Attempts++
+
+	if err := q.Nack(ctx, got, 0); err != nil {
+		t.Fatal(err)
+	}
+	assertLen(t, q, q.processingKey("worker-1"), 0)
+	assertQueued(t, q, job.ItemID, true)
+
+	again := dequeue(t, q, "worker-1")
+	if again.ID != job.ID || again.Attempts != 1 {
+		t.Fatalf("requeued job %+v, want attempt 1 of %s", again, job.ID)
+	}
+}
+
+func TestQueueNackWithDelay(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	enqueue(t, q)
+	got := dequeue(t, q, "worker-1")
+
+	if err := q.Nack(ctx, got, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	assertLen(t, q, q.queueKey, 0)
+
+	if n, err := q.PromoteDue(ctx); err != nil || n != 0 {
+		t.Fatalf("promoted %d (%v) before the delay elapsed", n, err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if n, err := q.PromoteDue(ctx); err != nil || n != 1 {
+		t.Fatalf("promoted %d (%v), want 1", n, err)
+	}
+	assertLen(t, q, q.queueKey, 1)
+}
+
+func TestQueueNackAfterReaperDoesNotDuplicate(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	enqueue(t, q)
+	got := dequeue(t, q, "worker-1")
+
+	// the consumer looks dead, the reaper hands its job back
+	if err := q.client.rdb.Del(ctx, q.heartbeatKey("worker-1")).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := q.RequeueOrphaned(ctx); err != nil || n != 1 {
+		t.Fatalf("recovered %d (%v), want 1", n, err)
+	}
+
+	if err := q.Nack(ctx, got, 0); err != nil {
+		t.Fatal(err)
+	}
+	assertLen(t, q, q.queueKey, 1)
+}
+
+func TestQueueDeadLetterAndReplay(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	job := enqueue(t, q)
+	got := dequeue(t, q, "worker-1")
+	got.Attempts = 5
+	got.LastError = "plaid unavailable"
+
+	if err := q.DeadLetter(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	assertLen(t, q, q.processingKey("worker-1"), 0)
+	assertQueued(t, q, job.ItemID, false)
+
+	dead, err := q.ListDeadLetters(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].ID != job.ID || dead[0].LastError != "plaid unavailable" {
+		t.Fatalf("dead letters = %+v, want the failed job", dead)
+	}
+
+	if err := q.ReplayDeadLetter(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	assertLen(t, q, q.deadLetterKey(), 0)
+	assertQueued(t, q, job.ItemID, true)
+
+	replayed := dequeue(t, q, "worker-1")
+	if replayed.ID != job.ID || replayed.Attempts != 0 || replayed.LastError != "" {
+		t.Fatalf("replayed job %+v, want a fresh attempt budget", replayed)
+	}
+
+	if err := q.ReplayDeadLetter(ctx, job.ID); !errors.Is(err, ErrJobNotDead) {
+		t.Fatalf("second replay = %v, want ErrJobNotDead", err)
+	}
+}
+
+func TestQueuePoisonPayloadIsDeadLettered(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	if err := q.client.rdb.RPush(ctx, q.queueKey, "not json").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := q.Dequeue(ctx, "worker-1", time.Second); err == nil {
+		t.Fatal("poison payload dequeued without error")
+	}
+	assertLen(t, q, q.processingKey("worker-1"), 0)
+	assertLen(t, q, q.queueKey, 0)
+
+	// nothing is left behind in the pending counts
+	n, err := q.client.rdb.HLen(ctx, q.pendingKey()).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("pending fields = %d, want 0", n)
+	}
+
+	// the raw payload is kept where it can be inspected
+	raw, err := q.client.rdb.LRange(ctx, q.deadLetterKey(), 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 1 || raw[0] != "not json" {
+		t.Fatalf("dead list = %q, want the raw payload", raw)
+	}
+
+	dead, err := q.ListDeadLetters(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || !strings.Contains(dead[0].LastError, "not json") {
+		t.Fatalf("dead letters = %+v, want the undecodable payload listed", dead)
+	}
+
+	// there is no job to replay, the entry stays
+	if err := q.ReplayDeadLetter(ctx, dead[0].ID); !errors.Is(err, ErrJobUndecodable) {
+		t.Fatalf("replay = %v, want ErrJobUndecodable", err)
+	}
+	assertLen(t, q, q.deadLetterKey(), 1)
+}
+
+func TestQueueRequeueOrphanedSkipsLiveConsumers(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	enqueue(t, q)
+	enqueue(t, q)
+	dequeue(t, q, "live")
+	dequeue(t, q, "dead")
+
+	if err := q.client.rdb.Del(ctx, q.heartbeatKey("dead")).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := q.RequeueOrphaned(ctx); err != nil || n != 1 {
+		t.Fatalf("recovered %d (%v), want 1", n, err)
+	}
+	assertLen(t, q, q.processingKey("live"), 1)
+	assertLen(t, q, q.processingKey("dead"), 0)
+	assertLen(t, q, q.queueKey, 1)
+}
+
+func TestDecodeDeadLetter(t *testing.T) {
+	job := decodeDeadLetter(`{"ID":"job-1","LastError":"boom"}`)
+	if job.ID != "job-1" || job.LastError != "boom" {
+		t.Fatalf("decoded %+v, want job-1", job)
+	}
+
+	poison := decodeDeadLetter("not json")
+	if poison.ID != undecodableJobID("not json") || poison.LastError != "undecodable payload: not json" {
+		t.Fatalf("decoded %+v, want the raw payload listed", poison)
+	}
+	if poison.ID == undecodableJobID("other garbage") {
+		t.Fatal("different payloads share an id")
+	}
+}
@@ -0,0 +1,63 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexchny/sync-relay/internal/cloudevents"
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/pkg/eventstream"
+	"github.com/redis/go-redis/v9"
+)
+
+// StreamPublisher appends events to redis streams, consumers read them with
+// pkg/eventstream. streams are trimmed to roughly maxLen entries.
+type StreamPublisher struct {
+	client    *Client
+	prefix    string
+	perTenant bool
+	maxLen    int64
+	source    string
+	mode      cloudevents.Mode
+}
+
+func NewStreamPublisher(client *Client, prefix string, perTenant bool, maxLen int64, source string, mode cloudevents.Mode) *StreamPublisher {
+	return &StreamPublisher{
+		client:    client,
+		prefix:    prefix,
+		perTenant: perTenant,
+		maxLen:    maxLen,
+		source:    source,
+		mode:      mode,
+	}
+}
+
+func (p *StreamPublisher) Publish(ctx context.Context, events ...*domain.Event) error {
+	for _, e := range events {
+		msg, err := cloudevents.Encode(cloudevents.FromEvent(e, p.source), p.mode)
+		if err != nil {
+			return err
+		}
+
+		values := map[string]interface{}{
+			eventstream.FieldContentType: msg.ContentType,
+			eventstream.FieldBody:        msg.Body,
+		}
+		for name, value := range msg.PrefixedHeaders(eventstream.FieldAttributePrefix) {
+			values[name] = value
+		}
+
+		stream := eventstream.StreamKey(p.prefix, e.TenantID.String(), p.perTenant)
+		err = p.client.rdb.XAdd(ctx, &redis.XAddArgs{
+			Stream: stream,
+			MaxLen: p.maxLen,
+			Approx: true,
+			Values: values,
+		}).Err()
+		if err != nil {
+			return fmt.Errorf("redis xadd to %s failed: %w", stream, err)
+		}
+	}
+
+	return nil
+}
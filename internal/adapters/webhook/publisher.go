@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/cloudevents"
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/netguard"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+const (
+	HeaderSignature = "X-Relay-Signature"
+	HeaderTimestamp = "X-Relay-Timestamp"
+	HeaderEventID   = "X-Relay-Event-Id"
+)
+
+// Sign returns the signature header value for a delivery. the timestamp is
+// part of the signed content so receivers can reject replays of old requests.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Publisher delivers events to every subscription of the event's tenant that
+// wants its type. a failed delivery fails Publish so the outbox relay retries
+// with backoff, subscriptions that already got the event are skipped.
+type Publisher struct {
+	subscriptions ports.WebhookSubscriptionRepository
+	deliveries    ports.WebhookDeliveryRepository
+	client        *http.Client
+	source        string
+	mode          cloudevents.Mode
+	maxAttempts   int
+}
+
+func NewPublisher(
+	subscriptions ports.WebhookSubscriptionRepository,
+	deliveries ports.WebhookDeliveryRepository,
+	timeout time.Duration,
+	source string,
+	mode cloudevents.Mode,
+	maxAttempts int,
+	allowPrivateTargets bool,
+) *Publisher {
+	client := &http.Client{Timeout: timeout}
+
+	// subscriptions were checked at registration, dns may point elsewhere by now
+	if !allowPrivateTargets {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = netguard.Dialer(timeout).DialContext
+		client.Transport = transport
+	}
+
+	return &Publisher{
+		subscriptions: subscriptions,
+		deliveries:    deliveries,
+		client:        client,
+		source:        source,
+		mode:          mode,
+		maxAttempts:   maxAttempts,
+	}
+}
+
+func (p *Publisher) Publish(ctx context.Context, events ...*domain.Event) error {
+	for _, e := range events {
+		subs, err := p.subscriptions.ListByTenant(ctx, e.TenantID)
+		if err != nil {
+			return err
+		}
+
+		msg, err := cloudevents.Encode(cloudevents.FromEvent(e, p.source), p.mode)
+		if err != nil {
+			return err
+		}
+
+		// try every subscriber before failing so one bad endpoint doesn't
+		// delay the others
+		var failed []error
+		for _, sub := range subs {
+			if !sub.Wants(e.Type) {
+				continue
+			}
+			if err := p.deliver(ctx, sub, e, msg); err != nil {
+				failed = append(failed, err)
+			}
+		}
+		if len(failed) > 0 {
+			return errors.Join(failed...)
+		}
+	}
+
+	return nil
+}
+
+func (p *Publisher) deliver(ctx context.Context, sub *domain.WebhookSubscription, e *domain.Event, msg *cloudevents.Message) error {
+	attempts, delivered, err := p.deliveries.Status(ctx, sub.ID, e.ID)
+	if err != nil {
+		return err
+	}
+	if delivered {
+		return nil
+	}
+
+	// give up on this subscriber so it can't hold back the tenant's events forever
+	if attempts >= p.maxAttempts {
+		slog.Error("webhook delivery abandoned",
+			"subscription_id", sub.ID,
+			"event_id", e.ID,
+			"attempts", attempts,
+		)
+		return nil
+	}
+
+	delivery := &domain.WebhookDelivery{
+		ID:             uuid.New(),
+		SubscriptionID: sub.ID,
+		EventID:        e.ID,
+		Attempt:        attempts + 1,
+	}
+
+	start := time.Now()
+	statusCode, sendErr := p.send(ctx, sub, e, msg)
+	delivery.Duration = time.Since(start)
+	delivery.StatusCode = statusCode
+	delivery.Delivered = sendErr == nil
+	if sendErr != nil {
+		delivery.Error = sendErr.Error()
+	}
+
+	if err := p.deliveries.Record(ctx, delivery); err != nil {
+		return err
+	}
+
+	if sendErr != nil {
+		return fmt.Errorf("webhook delivery to subscription %s failed: %w", sub.ID, sendErr)
+	}
+
+	return nil
+}
+
+func (p *Publisher) send(ctx context.Context, sub *domain.WebhookSubscription, e *domain.Event, msg *cloudevents.Message) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", msg.ContentType)
+	req.Header.Set(HeaderEventID, e.ID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, now, msg.Body))
+	for name, value := range msg.PrefixedHeaders("ce-") {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// drain so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
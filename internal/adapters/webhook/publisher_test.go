@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/cloudevents"
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/netguard"
+	"github.com/google/uuid"
+)
+
+type memSubscriptions struct {
+	subs []*domain.WebhookSubscription
+}
+
+func (m *memSubscriptions) Create(ctx context.Context, sub *domain.WebhookSubscription) error {
+	m.subs = append(m.subs, sub)
+	return nil
+}
+
+func (m *memSubscriptions) GetByID(ctx context.Context, tenantID, id uuid.UUID) (*domain.WebhookSubscription, error) {
+	for _, sub := range m.subs {
+		if sub.TenantID == tenantID && sub.ID == id {
+			return sub, nil
+		}
+	}
+	return nil, errors.New("subscription not found")
+}
+
+func (m *memSubscriptions) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*domain.WebhookSubscription, error) {
+	var subs []*domain.WebhookSubscription
+	for _, sub := range m.subs {
+		if sub.TenantID == tenantID {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func (m *memSubscriptions) Delete(ctx context.Context, tenantID, id uuid.UUID) error {
+	return nil
+}
+
+type memDeliveries struct {
+	mu      sync.Mutex
+	records []*domain.WebhookDelivery
+}
+
+func (m *memDeliveries) Record(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, delivery)
+	return nil
+}
+
+func (m *memDeliveries) Status(ctx context.Context, subscriptionID, eventID uuid.UUID) (int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts, delivered := 0, false
+	for _, d := range m.records {
+		if d.SubscriptionID == subscriptionID && d.EventID == eventID {
+			attempts++
+			delivered = delivered || d.Delivered
+		}
+	}
+	return attempts, delivered, nil
+}
+
+func (m *memDeliveries) ListBySubscription(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*domain.WebhookDelivery, error) {
+	return nil, nil
+}
+
+// receiver records requests and answers with status
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func setup(t *testing.T, status int, maxAttempts int, allowPrivateTargets bool) (*Publisher, *receiver, *memDeliveries, *domain.WebhookSubscription) {
+	t.Helper()
+
+	recv := &receiver{status: status}
+	server := httptest.NewServer(recv)
+	t.Cleanup(server.Close)
+
+	sub := &domain.WebhookSubscription{
+		ID:         uuid.New(),
+		TenantID:   uuid.New(),
+		URL:        server.URL,
+		EventTypes: []domain.EventType{domain.EventTypeTransactionsAdded},
+		Secret:     "whsec_test",
+	}
+	deliveries := &memDeliveries{}
+	publisher := NewPublisher(&memSubscriptions{subs: []*domain.WebhookSubscription{sub}}, deliveries, time.Second, "/test", cloudevents.ModeStructured, maxAttempts, allowPrivateTargets)
+
+	return publisher, recv, deliveries, sub
+}
+
+func TestSign(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"1"}`)
+
+	sig := Sign("secret", ts, body)
+	if sig != Sign("secret", ts, body) {
+		t.Fatal("signature is not deterministic")
+	}
+	if sig[:3] != "v1=" {
+		t.Fatalf("signature %q lacks the v1= prefix", sig)
+	}
+	if sig == Sign("other", ts, body) || sig == Sign("secret", ts.Add(time.Second), body) || sig == Sign("secret", ts, []byte(`{"id":"2"}`)) {
+		t.Fatal("signature doesn't cover secret, timestamp and body")
+	}
+}
+
+func TestPublishSignsDelivery(t *testing.T) {
+	publisher, recv, deliveries, sub := setup(t, http.StatusNoContent, 3, true)
+	event := domain.NewEvent(sub.TenantID, uuid.New(), domain.EventTypeTransactionsAdded, []byte(`{}`), "trace")
+
+	if err := publisher.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if recv.count() != 1 {
+		t.Fatalf("endpoint got %d requests, want 1", recv.count())
+	}
+
+	req, body := recv.requests[0], recv.bodies[0]
+	unix, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("bad timestamp header: %v", err)
+	}
+	if got, want := req.Header.Get(HeaderSignature), Sign(sub.Secret, time.Unix(unix, 0), body); got != want {
+		t.Fatalf("signature = %s, want %s", got, want)
+	}
+	if got := req.Header.Get(HeaderEventID); got != event.ID.String() {
+		t.Fatalf("event id header = %s, want %s", got, event.ID)
+	}
+
+	if len(deliveries.records) != 1 || !deliveries.records[0].Delivered || deliveries.records[0].StatusCode != http.StatusNoContent {
+		t.Fatalf("recorded %+v, want one successful delivery", deliveries.records)
+	}
+
+	// a redelivered event isn't sent twice
+	if err := publisher.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if recv.count() != 1 {
+		t.Fatalf("endpoint got %d requests after redelivery, want 1", recv.count())
+	}
+}
+
+func TestPublishSkipsUnwantedTypes(t *testing.T) {
+	publisher, recv, _, sub := setup(t, http.StatusOK, 3, true)
+	event := domain.NewEvent(sub.TenantID, uuid.New(), domain.EventTypeItemError, []byte(`{}`), "trace")
+
+	if err := publisher.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if recv.count() != 0 {
+		t.Fatalf("endpoint got %d requests for an unsubscribed type", recv.count())
+	}
+}
+
+func TestPublishFailureAbandonedAfterMaxAttempts(t *testing.T) {
+	publisher, recv, deliveries, sub := setup(t, http.StatusInternalServerError, 2, true)
+	event := domain.NewEvent(sub.TenantID, uuid.New(), domain.EventTypeTransactionsAdded, []byte(`{}`), "trace")
+
+	for i := range 2 {
+		if err := publisher.Publish(context.Background(), event); err == nil {
+			t.Fatalf("attempt %d succeeded against a failing endpoint", i+1)
+		}
+	}
+	if len(deliveries.records) != 2 || deliveries.records[1].Attempt != 2 || deliveries.records[1].StatusCode != http.StatusInternalServerError {
+		t.Fatalf("recorded %+v, want two failed attempts", deliveries.records)
+	}
+
+	// out of attempts, the event no longer holds back the outbox
+	if err := publisher.Publish(context.Background(), event); err != nil {
+		t.Fatalf("publish after max attempts = %v, want nil", err)
+	}
+	if recv.count() != 2 {
+		t.Fatalf("endpoint got %d requests, want 2", recv.count())
+	}
+}
+
+func TestPublishRefusesPrivateTarget(t *testing.T) {
+	publisher, recv, deliveries, sub := setup(t, http.StatusOK, 3, false)
+	event := domain.NewEvent(sub.TenantID, uuid.New(), domain.EventTypeTransactionsAdded, []byte(`{}`), "trace")
+
+	// the test server listens on loopback
+	err := publisher.Publish(context.Background(), event)
+	if !errors.Is(err, netguard.ErrForbiddenAddress) {
+		t.Fatalf("error = %v, want ErrForbiddenAddress", err)
+	}
+	if recv.count() != 0 {
+		t.Fatal("request reached a loopback endpoint")
+	}
+	if len(deliveries.records) != 1 || deliveries.records[0].Delivered {
+		t.Fatalf("recorded %+v, want one failed delivery", deliveries.records)
+	}
+}
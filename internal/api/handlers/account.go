@@ -3,9 +3,13 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
 	"github.com/alexchny/sync-relay/internal/service"
 	"github.com/google/uuid"
 )
@@ -24,11 +28,26 @@ func (h *AccountHandler) CreateLinkToken(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID := "00000000-0000-0000-0000-000000000001"
+	// end users send no body, their id comes from the session token
+	var req struct {
+		EndUserID string `json:"end_user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
 
-	token, err := h.service.CreateLinkToken(r.Context(), userID)
+	endUserID := requestEndUser(r, req.EndUserID)
+	if err := validateEndUserID(endUserID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID := requestTenant(r)
+
+	token, err := h.service.CreateLinkToken(r.Context(), tenantID, endUserID)
 	if err != nil {
-		slog.Error("failed to create link token", "user_id", userID, "error", err)
+		slog.Error("failed to create link token", "tenant_id", tenantID, "end_user_id", endUserID, "error", err)
 		http.Error(w, "failed to create link token", http.StatusInternalServerError)
 		return
 	}
@@ -47,6 +66,7 @@ func (h *AccountHandler) ConnectItem(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		PublicToken string `json:"public_token"`
+		EndUserID   string `json:"end_user_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid json", http.StatusBadRequest)
@@ -58,9 +78,15 @@ func (h *AccountHandler) ConnectItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tenantID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	endUserID := requestEndUser(r, req.EndUserID)
+	if err := validateEndUserID(endUserID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID := requestTenant(r)
 
-	itemID, err := h.service.LinkItem(r.Context(), tenantID, req.PublicToken)
+	itemID, err := h.service.LinkItem(r.Context(), tenantID, endUserID, req.PublicToken)
 	if err != nil {
 		// token already used
 		if errors.Is(err, service.ErrTokenAlreadyUsed) {
@@ -81,6 +107,12 @@ func (h *AccountHandler) ConnectItem(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if errors.Is(err, service.ErrItemOwnedElsewhere) {
+			slog.Warn("item linked by another tenant", "tenant_id", tenantID)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
 		// other errors
 		slog.Error("failed to connect item", "tenant_id", tenantID, "error", err)
 		http.Error(w, "failed to connect item", http.StatusInternalServerError)
@@ -94,3 +126,169 @@ func (h *AccountHandler) ConnectItem(w http.ResponseWriter, r *http.Request) {
 		"status":  "sync_queued",
 	})
 }
+
+func (h *AccountHandler) CreateUpdateLinkToken(w http.ResponseWriter, r *http.Request) {
+	itemID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid item id", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.service.CreateUpdateLinkToken(r.Context(), requestTenant(r), itemID)
+	if err != nil {
+		if errors.Is(err, ports.ErrItemNotFound) {
+			http.Error(w, "item not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrItemRevoked) || errors.Is(err, service.ErrItemRemoved) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		slog.Error("failed to create update link token", "item_id", itemID, "error", err)
+		http.Error(w, "failed to create link token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"link_token": token,
+	})
+}
+
+func (h *AccountHandler) CompleteItemRepair(w http.ResponseWriter, r *http.Request) {
+	itemID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid item id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CompleteItemRepair(r.Context(), requestTenant(r), itemID); err != nil {
+		if errors.Is(err, ports.ErrItemNotFound) {
+			http.Error(w, "item not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrItemRevoked) || errors.Is(err, service.ErrItemRemoved) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		slog.Error("failed to complete item repair", "item_id", itemID, "error", err)
+		http.Error(w, "failed to complete item repair", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"item_id": itemID.String(),
+		"status":  "sync_queued",
+	})
+}
+
+func (h *AccountHandler) DeleteItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid item id", http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.service.RemoveItem(r.Context(), requestTenant(r), itemID)
+	if err != nil {
+		if errors.Is(err, ports.ErrItemNotFound) {
+			http.Error(w, "item not found", http.StatusNotFound)
+			return
+		}
+
+		slog.Error("failed to remove item", "item_id", itemID, "error", err)
+		http.Error(w, "failed to remove item", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newItemResponse(item))
+}
+
+func (h *AccountHandler) ReconcileItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid item id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RequestReconciliation(r.Context(), requestTenant(r), itemID); err != nil {
+		if errors.Is(err, ports.ErrItemNotFound) {
+			http.Error(w, "item not found", http.StatusNotFound)
+			return
+		}
+
+		slog.Error("failed to queue reconciliation", "item_id", itemID, "error", err)
+		http.Error(w, "failed to queue reconciliation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"item_id": itemID.String(),
+		"status":  "reconciliation_queued",
+	})
+}
+
+type itemErrorResponse struct {
+	Code        string               `json:"code,omitempty"`
+	Category    domain.ErrorCategory `json:"category"`
+	Message     string               `json:"message,omitempty"`
+	FirstSeenAt *time.Time           `json:"first_seen_at,omitempty"`
+	RetryCount  int                  `json:"retry_count"`
+}
+
+type itemResponse struct {
+	ID                       string             `json:"id"`
+	EndUserID                string             `json:"end_user_id,omitempty"`
+	Status                   domain.SyncStatus  `json:"status"`
+	LastSyncedAt             *time.Time         `json:"last_synced_at,omitempty"`
+	InitialUpdateComplete    bool               `json:"initial_update_complete"`
+	HistoricalUpdateComplete bool               `json:"historical_update_complete"`
+	ConsentExpiresAt         *time.Time         `json:"consent_expires_at,omitempty"`
+	PurgeAfter               *time.Time         `json:"purge_after,omitempty"`
+	Error                    *itemErrorResponse `json:"error,omitempty"`
+	CreatedAt                time.Time          `json:"created_at"`
+}
+
+func newItemResponse(item *domain.Item) itemResponse {
+	resp := itemResponse{
+		ID:                       item.ID.String(),
+		EndUserID:                item.EndUserID,
+		Status:                   item.SyncStatus,
+		LastSyncedAt:             item.LastSyncedAt,
+		InitialUpdateComplete:    item.InitialUpdateComplete,
+		HistoricalUpdateComplete: item.HistoricalUpdateComplete,
+		ConsentExpiresAt:         item.ConsentExpiresAt,
+		PurgeAfter:               item.PurgeAfter,
+		CreatedAt:                item.CreatedAt,
+	}
+
+	// transient failures show up here too while the item is still active
+	if item.ErrorCategory != "" {
+		resp.Error = &itemErrorResponse{
+			Code:        item.ErrorCode,
+			Category:    item.ErrorCategory,
+			Message:     item.ErrorMessage,
+			FirstSeenAt: item.ErrorFirstSeenAt,
+			RetryCount:  item.ErrorRetryCount,
+		}
+	}
+
+	return resp
+}
+
+func validateEndUserID(id string) error {
+	if id == "" {
+		return errors.New("end_user_id is required")
+	}
+	if len(id) > 128 {
+		return errors.New("end_user_id must be at most 128 characters")
+	}
+	return nil
+}
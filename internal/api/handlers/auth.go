@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/alexchny/sync-relay/internal/service"
+	"github.com/google/uuid"
+)
+
+// RequireAPIKey resolves the tenant from the request's api key, sent as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>"
+func RequireAPIKey(auth *service.AuthService) func(http.Handler) http.Handler {
+	return authenticate(auth, false)
+}
+
+// AllowEndUser also accepts an end user's session token from the identity
+// provider, for the routes a frontend calls directly
+func AllowEndUser(auth *service.AuthService) func(http.Handler) http.Handler {
+	return authenticate(auth, true)
+}
+
+func authenticate(auth *service.AuthService, allowEndUser bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			credential := r.Header.Get("X-API-Key")
+			if credential == "" {
+				credential = bearerToken(r)
+			}
+			if credential == "" {
+				unauthorized(w)
+				return
+			}
+
+			var principal service.Principal
+			var err error
+			switch {
+			case service.IsAPIKey(credential):
+				principal, err = auth.AuthenticateAPIKey(r.Context(), credential)
+			case allowEndUser:
+				principal, err = auth.AuthenticateSessionToken(r.Context(), credential)
+			default:
+				err = service.ErrUnauthenticated
+			}
+			if err != nil {
+				if !errors.Is(err, service.ErrUnauthenticated) {
+					slog.Error("failed to authenticate request", "error", err)
+					http.Error(w, "internal server error", http.StatusInternalServerError)
+					return
+				}
+				slog.Warn("rejected credentials", "ip", r.RemoteAddr, "path", r.URL.Path)
+				unauthorized(w)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(service.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="sync-relay"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+// requestTenant is only called behind the auth middleware
+func requestTenant(r *http.Request) uuid.UUID {
+	principal, _ := service.PrincipalFromContext(r.Context())
+	return principal.TenantID
+}
+
+// requestEndUser prefers the session token's end user over one named in the
+// body, an end user can't link items for someone else
+func requestEndUser(r *http.Request, fromBody string) string {
+	principal, _ := service.PrincipalFromContext(r.Context())
+	if principal.EndUserID != "" {
+		return principal.EndUserID
+	}
+	return fromBody
+}
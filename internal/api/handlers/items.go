@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/alexchny/sync-relay/internal/service"
+	"github.com/google/uuid"
+)
+
+type ItemHandler struct {
+	service *service.ItemService
+}
+
+func NewItemHandler(s *service.ItemService) *ItemHandler {
+	return &ItemHandler{service: s}
+}
+
+type itemOverviewResponse struct {
+	itemResponse
+	Transactions struct {
+		Pending int64 `json:"pending"`
+		Posted  int64 `json:"posted"`
+		Removed int64 `json:"removed"`
+	} `json:"transactions"`
+	Sync struct {
+		Queued  bool `json:"queued"`
+		Running bool `json:"running"`
+	} `json:"sync"`
+}
+
+func newItemOverviewResponse(o *service.ItemOverview) itemOverviewResponse {
+	resp := itemOverviewResponse{itemResponse: newItemResponse(o.Item)}
+	resp.Transactions.Pending = o.Transactions.Pending
+	resp.Transactions.Posted = o.Transactions.Posted
+	resp.Transactions.Removed = o.Transactions.Removed
+	resp.Sync.Queued = o.Queued
+	resp.Sync.Running = o.Running
+	return resp
+}
+
+func (h *ItemHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter := ports.ItemFilter{
+		EndUserID: q.Get("end_user_id"),
+		Status:    domain.SyncStatus(q.Get("status")),
+		Limit:     50,
+	}
+
+	// a typo would otherwise quietly return no items
+	if filter.Status != "" && !filter.Status.IsKnown() {
+		http.Error(w, "unknown status: "+string(filter.Status), http.StatusBadRequest)
+		return
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 200 {
+			http.Error(w, "limit must be between 1 and 200", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	tenantID := requestTenant(r)
+
+	page, err := h.service.List(r.Context(), tenantID, filter, q.Get("cursor"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		slog.Error("failed to list items", "tenant_id", tenantID, "error", err)
+		http.Error(w, "failed to list items", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]itemOverviewResponse, 0, len(page.Items))
+	for _, o := range page.Items {
+		resp = append(resp, newItemOverviewResponse(o))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"items":       resp,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+func (h *ItemHandler) Get(w http.ResponseWriter, r *http.Request) {
+	itemID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid item id", http.StatusBadRequest)
+		return
+	}
+
+	overview, err := h.service.Get(r.Context(), requestTenant(r), itemID)
+	if err != nil {
+		if errors.Is(err, ports.ErrItemNotFound) {
+			http.Error(w, "item not found", http.StatusNotFound)
+			return
+		}
+
+		slog.Error("failed to get item", "item_id", itemID, "error", err)
+		http.Error(w, "failed to get item", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newItemOverviewResponse(overview))
+}
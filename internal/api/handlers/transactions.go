@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/alexchny/sync-relay/internal/service"
+	"github.com/google/uuid"
+)
+
+type TransactionHandler struct {
+	service *service.TransactionService
+}
+
+func NewTransactionHandler(s *service.TransactionService) *TransactionHandler {
+	return &TransactionHandler{service: s}
+}
+
+type transactionResponse struct {
+	ID                   string                   `json:"id"`
+	ItemID               string                   `json:"item_id"`
+	AccountID            string                   `json:"account_id,omitempty"`
+	PlaidTransactionID   string                   `json:"plaid_transaction_id"`
+	PendingTransactionID *string                  `json:"pending_transaction_id,omitempty"`
+	AmountCents          int64                    `json:"amount_cents"`
+	CurrencyCode         string                   `json:"currency_code"`
+	Date                 string                   `json:"date"`
+	MerchantName         string                   `json:"merchant_name"`
+	Status               domain.TransactionStatus `json:"status"`
+	Removed              bool                     `json:"removed"`
+	PhantomAt            *time.Time               `json:"phantom_at,omitempty"`
+	CreatedAt            time.Time                `json:"created_at"`
+	UpdatedAt            time.Time                `json:"updated_at"`
+}
+
+func newTransactionResponse(tx *domain.Transaction) transactionResponse {
+	return transactionResponse{
+		ID:                   tx.ID.String(),
+		ItemID:               tx.ItemID.String(),
+		AccountID:            tx.AccountID,
+		PlaidTransactionID:   tx.PlaidTransactionID,
+		PendingTransactionID: tx.PlaidPendingID,
+		AmountCents:          tx.AmountCents,
+		CurrencyCode:         tx.CurrencyCode,
+		Date:                 tx.Date.Format("2006-01-02"),
+		MerchantName:         tx.MerchantName,
+		Status:               tx.Status,
+		Removed:              tx.IsRemoved,
+		PhantomAt:            tx.PhantomAt,
+		CreatedAt:            tx.CreatedAt,
+		UpdatedAt:            tx.UpdatedAt,
+	}
+}
+
+func (h *TransactionHandler) List(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseTransactionFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID := requestTenant(r)
+
+	page, err := h.service.List(r.Context(), tenantID, filter, r.URL.Query().Get("cursor"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) || errors.Is(err, service.ErrInvalidTransactionQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		slog.Error("failed to list transactions", "tenant_id", tenantID, "error", err)
+		http.Error(w, "failed to list transactions", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]transactionResponse, 0, len(page.Transactions))
+	for _, tx := range page.Transactions {
+		resp = append(resp, newTransactionResponse(tx))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"transactions": resp,
+		"next_cursor":  page.NextCursor,
+	})
+}
+
+func parseTransactionFilter(q url.Values) (ports.TransactionFilter, error) {
+	filter := ports.TransactionFilter{
+		AccountID: q.Get("account_id"),
+		Merchant:  q.Get("merchant"),
+		Limit:     100,
+	}
+
+	if v := q.Get("item_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid item_id")
+		}
+		filter.ItemID = &id
+	}
+
+	for name, dst := range map[string]**time.Time{
+		"start_date": &filter.StartDate,
+		"end_date":   &filter.EndDate,
+	} {
+		if v := q.Get(name); v != "" {
+			date, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be a YYYY-MM-DD date", name)
+			}
+			*dst = &date
+		}
+	}
+
+	for name, dst := range map[string]**int64{
+		"min_amount_cents": &filter.MinAmountCents,
+		"max_amount_cents": &filter.MaxAmountCents,
+	} {
+		if v := q.Get(name); v != "" {
+			amount, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an integer", name)
+			}
+			*dst = &amount
+		}
+	}
+
+	switch status := domain.TransactionStatus(q.Get("status")); status {
+	case "", domain.TransactionStatusPending, domain.TransactionStatusPosted:
+		filter.Status = status
+	default:
+		return filter, fmt.Errorf("status must be pending or posted")
+	}
+
+	if v := q.Get("include_removed"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("include_removed must be true or false")
+		}
+		filter.IncludeRemoved = include
+	}
+
+	if v := q.Get("phantom"); v != "" {
+		phantom, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("phantom must be true or false")
+		}
+		filter.Phantom = &phantom
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 500 {
+			return filter, fmt.Errorf("limit must be between 1 and 500")
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
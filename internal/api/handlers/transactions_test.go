@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/google/uuid"
+)
+
+func TestParseTransactionFilter(t *testing.T) {
+	itemID := uuid.New()
+
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{
+			name:  "defaults",
+			query: "",
+		},
+		{
+			name: "all filters",
+			query: "item_id=" + itemID.String() + "&account_id=acc-1&merchant=coffee&start_date=2024-01-01&end_date=2024-01-31" +
+				"&min_amount_cents=-500&max_amount_cents=10000&status=posted&include_removed=true&phantom=true&limit=500",
+		},
+		{name: "bad item id", query: "item_id=nope", wantErr: "invalid item_id"},
+		{name: "bad start date", query: "start_date=2024-1-1", wantErr: "start_date must be a YYYY-MM-DD date"},
+		{name: "bad end date", query: "end_date=yesterday", wantErr: "end_date must be a YYYY-MM-DD date"},
+		{name: "bad amount", query: "min_amount_cents=1.50", wantErr: "min_amount_cents must be an integer"},
+		{name: "bad status", query: "status=removed", wantErr: "status must be pending or posted"},
+		{name: "bad include_removed", query: "include_removed=maybe", wantErr: "include_removed must be true or false"},
+		{name: "bad phantom", query: "phantom=1x", wantErr: "phantom must be true or false"},
+		{name: "limit too small", query: "limit=0", wantErr: "limit must be between 1 and 500"},
+		{name: "limit too large", query: "limit=501", wantErr: "limit must be between 1 and 500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			filter, err := parseTransactionFilter(q)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.query == "" {
+				if filter.Limit != 100 || filter.ItemID != nil || filter.StartDate != nil || filter.Status != "" || filter.IncludeRemoved || filter.Phantom != nil {
+					t.Fatalf("unexpected defaults: %+v", filter)
+				}
+				return
+			}
+
+			if filter.ItemID == nil || *filter.ItemID != itemID {
+				t.Errorf("item_id = %v, want %s", filter.ItemID, itemID)
+			}
+			if filter.AccountID != "acc-1" || filter.Merchant != "coffee" {
+				t.Errorf("account_id, merchant = %q, %q", filter.AccountID, filter.Merchant)
+			}
+			if filter.StartDate == nil || !filter.StartDate.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("start_date = %v", filter.StartDate)
+			}
+			if filter.EndDate == nil || !filter.EndDate.Equal(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("end_date = %v", filter.EndDate)
+			}
+			if filter.MinAmountCents == nil || *filter.MinAmountCents != -500 {
+				t.Errorf("min_amount_cents = %v", filter.MinAmountCents)
+			}
+			if filter.MaxAmountCents == nil || *filter.MaxAmountCents != 10000 {
+				t.Errorf("max_amount_cents = %v", filter.MaxAmountCents)
+			}
+			if filter.Phantom == nil || !*filter.Phantom {
+				t.Errorf("phantom = %v, want true", filter.Phantom)
+			}
+			if filter.Status != domain.TransactionStatusPosted || !filter.IncludeRemoved || filter.Limit != 500 {
+				t.Errorf("status, include_removed, limit = %q, %v, %d", filter.Status, filter.IncludeRemoved, filter.Limit)
+			}
+		})
+	}
+}
@@ -1,25 +1,23 @@
 package handlers
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 
-	"github.com/alexchny/sync-relay/internal/domain"
 	"github.com/alexchny/sync-relay/internal/ports"
-	"github.com/google/uuid"
+	"github.com/alexchny/sync-relay/internal/service"
 )
 
 type WebhookHandler struct {
 	verifier ports.WebhookVerifier
-	itemRepo ports.ItemRepository
-	queue    ports.JobQueue
+	service  *service.PlaidWebhookService
 }
 
-func NewWebhookHandler(v ports.WebhookVerifier, r ports.ItemRepository, q ports.JobQueue) *WebhookHandler {
+func NewWebhookHandler(v ports.WebhookVerifier, s *service.PlaidWebhookService) *WebhookHandler {
 	return &WebhookHandler{
 		verifier: v,
-		itemRepo: r,
-		queue:    q,
+		service:  s,
 	}
 }
 
@@ -36,31 +34,18 @@ func (h *WebhookHandler) HandlePlaidWebhook(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if payload.WebhookType != "TRANSACTIONS" || payload.WebhookCode != "SYNC_UPDATES_AVAILABLE" {
-		slog.Debug("ignoring webhook", "type", payload.WebhookType, "code", payload.WebhookCode)
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	item, err := h.itemRepo.GetByPlaidItemID(r.Context(), payload.ItemID)
-	if err != nil {
-		slog.Error("unknown item in webhook", "plaid_item_id", payload.ItemID, "error", err)
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	job := &domain.SyncJob{
-		ItemID:  item.ID,
-		JobType: domain.JobTypeStandard,
-		TraceID: uuid.NewString(),
-	}
+	if err := h.service.Handle(r.Context(), payload); err != nil {
+		// plaid retries non-2xx responses, an unknown item won't appear later
+		if errors.Is(err, service.ErrUnknownItem) {
+			slog.Error("unknown item in webhook", "plaid_item_id", payload.ItemID, "error", err)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 
-	if err := h.queue.Enqueue(r.Context(), job); err != nil {
-		slog.Error("failed to enqueue sync job", "item_id", item.ID, "error", err)
+		slog.Error("failed to handle webhook", "type", payload.WebhookType, "code", payload.WebhookCode, "error", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	slog.Info("sync job enqueued", "item_id", item.ID, "webhook_type", payload.WebhookType)
 	w.WriteHeader(http.StatusAccepted)
 }
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/alexchny/sync-relay/internal/service"
+	"github.com/google/uuid"
+)
+
+type WebhookSubscriptionHandler struct {
+	service *service.WebhookService
+}
+
+func NewWebhookSubscriptionHandler(s *service.WebhookService) *WebhookSubscriptionHandler {
+	return &WebhookSubscriptionHandler{service: s}
+}
+
+type subscriptionResponse struct {
+	ID         string             `json:"id"`
+	URL        string             `json:"url"`
+	EventTypes []domain.EventType `json:"event_types"`
+	// only returned when the subscription is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newSubscriptionResponse(sub *domain.WebhookSubscription) subscriptionResponse {
+	return subscriptionResponse{
+		ID:         sub.ID.String(),
+		URL:        sub.URL,
+		EventTypes: sub.EventTypes,
+		CreatedAt:  sub.CreatedAt,
+	}
+}
+
+func (h *WebhookSubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	tenantID := requestTenant(r)
+
+	sub, err := h.service.CreateSubscription(r.Context(), tenantID, req.URL, req.EventTypes)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWebhookURL) || errors.Is(err, service.ErrInvalidEventType) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		slog.Error("failed to create webhook subscription", "tenant_id", tenantID, "error", err)
+		http.Error(w, "failed to create webhook subscription", http.StatusInternalServerError)
+		return
+	}
+
+	resp := newSubscriptionResponse(sub)
+	resp.Secret = sub.Secret
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (h *WebhookSubscriptionHandler) List(w http.ResponseWriter, r *http.Request) {
+	tenantID := requestTenant(r)
+
+	subs, err := h.service.ListSubscriptions(r.Context(), tenantID)
+	if err != nil {
+		slog.Error("failed to list webhook subscriptions", "tenant_id", tenantID, "error", err)
+		http.Error(w, "failed to list webhook subscriptions", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]subscriptionResponse, 0, len(subs))
+	for _, sub := range subs {
+		resp = append(resp, newSubscriptionResponse(sub))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"subscriptions": resp,
+	})
+}
+
+func (h *WebhookSubscriptionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid subscription id", http.StatusBadRequest)
+		return
+	}
+
+	tenantID := requestTenant(r)
+
+	if err := h.service.DeleteSubscription(r.Context(), tenantID, id); err != nil {
+		if errors.Is(err, ports.ErrSubscriptionNotFound) {
+			http.Error(w, "subscription not found", http.StatusNotFound)
+			return
+		}
+
+		slog.Error("failed to delete webhook subscription", "subscription_id", id, "error", err)
+		http.Error(w, "failed to delete webhook subscription", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebhookSubscriptionHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid subscription id", http.StatusBadRequest)
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 500 {
+			http.Error(w, "limit must be between 1 and 500", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tenantID := requestTenant(r)
+
+	deliveries, err := h.service.ListDeliveries(r.Context(), tenantID, id, limit)
+	if err != nil {
+		if errors.Is(err, ports.ErrSubscriptionNotFound) {
+			http.Error(w, "subscription not found", http.StatusNotFound)
+			return
+		}
+
+		slog.Error("failed to list webhook deliveries", "subscription_id", id, "error", err)
+		http.Error(w, "failed to list webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	type deliveryResponse struct {
+		ID         string    `json:"id"`
+		EventID    string    `json:"event_id"`
+		Attempt    int       `json:"attempt"`
+		StatusCode int       `json:"status_code,omitempty"`
+		Error      string    `json:"error,omitempty"`
+		DurationMs int64     `json:"duration_ms"`
+		Delivered  bool      `json:"delivered"`
+		CreatedAt  time.Time `json:"created_at"`
+	}
+
+	resp := make([]deliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		resp = append(resp, deliveryResponse{
+			ID:         d.ID.String(),
+			EventID:    d.EventID.String(),
+			Attempt:    d.Attempt,
+			StatusCode: d.StatusCode,
+			Error:      d.Error,
+			DurationMs: d.Duration.Milliseconds(),
+			Delivered:  d.Delivered,
+			CreatedAt:  d.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"deliveries": resp,
+	})
+}
@@ -0,0 +1,154 @@
+package cloudevents
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+)
+
+const SpecVersion = "1.0"
+
+// prefix for every event type we emit, e.g. relay.transactions.added
+const TypePrefix = "relay."
+
+const (
+	ContentTypeJSON       = "application/json"
+	ContentTypeStructured = "application/cloudevents+json"
+)
+
+type Mode string
+
+const (
+	// attributes and data in one json document
+	ModeStructured Mode = "structured"
+	// attributes in transport headers, data as the body
+	ModeBinary Mode = "binary"
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case ModeStructured, ModeBinary:
+		return Mode(s), nil
+	}
+	return "", fmt.Errorf("%s (must be structured or binary)", s)
+}
+
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	TraceParent     string          `json:"traceparent,omitempty"`
+	TenantID        string          `json:"tenantid"`
+	Data            json.RawMessage `json:"data"`
+}
+
+func FromEvent(e *domain.Event, source string) *CloudEvent {
+	return &CloudEvent{
+		SpecVersion:     SpecVersion,
+		ID:              e.ID.String(),
+		Source:          source,
+		Type:            TypePrefix + string(e.Type),
+		Subject:         e.ItemID.String(),
+		Time:            e.CreatedAt.UTC(),
+		DataContentType: ContentTypeJSON,
+		TraceParent:     TraceParent(e.TraceID, e.ID.String()),
+		TenantID:        e.TenantID.String(),
+		Data:            json.RawMessage(e.Payload),
+	}
+}
+
+// Message is an encoded event ready for a transport. header names are the
+// bare attribute names, transports add their binding's prefix.
+type Message struct {
+	ContentType string
+	Headers     map[string]string
+	Body        []byte
+}
+
+func Encode(ce *CloudEvent, mode Mode) (*Message, error) {
+	if mode == ModeBinary {
+		return &Message{
+			ContentType: ce.DataContentType,
+			Headers:     ce.attributes(),
+			Body:        ce.Data,
+		}, nil
+	}
+
+	body, err := json.Marshal(ce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cloudevent: %w", err)
+	}
+
+	return &Message{
+		ContentType: ContentTypeStructured,
+		Headers:     map[string]string{},
+		Body:        body,
+	}, nil
+}
+
+// PrefixedHeaders returns the headers with a binding prefix applied, e.g.
+// "ce-" for http and nats or "ce_" for kafka.
+func (m *Message) PrefixedHeaders(prefix string) map[string]string {
+	headers := make(map[string]string, len(m.Headers))
+	for name, value := range m.Headers {
+		headers[prefix+name] = value
+	}
+	return headers
+}
+
+func (ce *CloudEvent) attributes() map[string]string {
+	attrs := map[string]string{
+		"specversion": ce.SpecVersion,
+		"id":          ce.ID,
+		"source":      ce.Source,
+		"type":        ce.Type,
+		"subject":     ce.Subject,
+		"time":        ce.Time.Format(time.RFC3339Nano),
+		"tenantid":    ce.TenantID,
+	}
+	if ce.TraceParent != "" {
+		attrs["traceparent"] = ce.TraceParent
+	}
+	return attrs
+}
+
+// TraceParent builds a W3C traceparent from a job trace id. uuids and 32-char
+// hex ids map directly, anything else is hashed into a trace id. the parent
+// span id is derived from the event id so it is stable across redeliveries.
+func TraceParent(traceID, spanSeed string) string {
+	if traceID == "" {
+		return ""
+	}
+
+	traceHex := strings.ToLower(strings.ReplaceAll(traceID, "-", ""))
+	if !isHex(traceHex, 32) || strings.Trim(traceHex, "0") == "" {
+		sum := sha256.Sum256([]byte(traceID))
+		traceHex = hex.EncodeToString(sum[:16])
+	}
+
+	spanSum := sha256.Sum256([]byte(spanSeed))
+	spanHex := hex.EncodeToString(spanSum[:8])
+
+	return fmt.Sprintf("00-%s-%s-01", traceHex, spanHex)
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/google/uuid"
+)
+
+func testEvent(traceID string) *domain.Event {
+	return &domain.Event{
+		ID:        uuid.MustParse("7d1b3f5e-2a4c-4e8b-9f10-1c2d3e4f5a6b"),
+		TenantID:  uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		ItemID:    uuid.MustParse("a0b1c2d3-e4f5-4a6b-8c7d-9e0f1a2b3c4d"),
+		Type:      domain.EventTypeTransactionsAdded,
+		Payload:   []byte(`{"item_id":"item-1"}`),
+		TraceID:   traceID,
+		CreatedAt: time.Date(2024, 3, 15, 12, 30, 0, 0, time.FixedZone("EST", -5*3600)),
+	}
+}
+
+func TestEncode(t *testing.T) {
+	ce := FromEvent(testEvent("4bf92f35-77b3-4da6-a3ce-929d0e0e4736"), "/sync-relay")
+
+	tests := []struct {
+		name            string
+		mode            Mode
+		wantContentType string
+		check           func(t *testing.T, msg *Message)
+	}{
+		{
+			name:            "structured",
+			mode:            ModeStructured,
+			wantContentType: ContentTypeStructured,
+			check: func(t *testing.T, msg *Message) {
+				if len(msg.Headers) != 0 {
+					t.Errorf("headers = %v, want none", msg.Headers)
+				}
+
+				var doc map[string]json.RawMessage
+				if err := json.Unmarshal(msg.Body, &doc); err != nil {
+					t.Fatalf("body is not json: %v", err)
+				}
+				for attr, want := range map[string]string{
+					"specversion":     `"1.0"`,
+					"id":              `"7d1b3f5e-2a4c-4e8b-9f10-1c2d3e4f5a6b"`,
+					"source":          `"/sync-relay"`,
+					"type":            `"relay.transactions.added"`,
+					"subject":         `"a0b1c2d3-e4f5-4a6b-8c7d-9e0f1a2b3c4d"`,
+					"time":            `"2024-03-15T17:30:00Z"`,
+					"datacontenttype": `"application/json"`,
+					"tenantid":        `"00000000-0000-0000-0000-000000000001"`,
+					"data":            `{"item_id":"item-1"}`,
+				} {
+					if got := string(doc[attr]); got != want {
+						t.Errorf("%s = %s, want %s", attr, got, want)
+					}
+				}
+				if _, ok := doc["traceparent"]; !ok {
+					t.Error("traceparent missing")
+				}
+			},
+		},
+		{
+			name:            "binary",
+			mode:            ModeBinary,
+			wantContentType: ContentTypeJSON,
+			check: func(t *testing.T, msg *Message) {
+				if string(msg.Body) != `{"item_id":"item-1"}` {
+					t.Errorf("body = %s, want the bare payload", msg.Body)
+				}
+				for attr, want := range map[string]string{
+					"specversion": "1.0",
+					"id":          "7d1b3f5e-2a4c-4e8b-9f10-1c2d3e4f5a6b",
+					"source":      "/sync-relay",
+					"type":        "relay.transactions.added",
+					"subject":     "a0b1c2d3-e4f5-4a6b-8c7d-9e0f1a2b3c4d",
+					"time":        "2024-03-15T17:30:00Z",
+					"tenantid":    "00000000-0000-0000-0000-000000000001",
+					"traceparent": ce.TraceParent,
+				} {
+					if got := msg.Headers[attr]; got != want {
+						t.Errorf("header %s = %q, want %q", attr, got, want)
+					}
+				}
+
+				prefixed := msg.PrefixedHeaders("ce-")
+				if prefixed["ce-id"] != ce.ID || len(prefixed) != len(msg.Headers) {
+					t.Errorf("prefixed headers = %v", prefixed)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Encode(ce, tt.mode)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if msg.ContentType != tt.wantContentType {
+				t.Errorf("content type = %q, want %q", msg.ContentType, tt.wantContentType)
+			}
+			tt.check(t, msg)
+		})
+	}
+}
+
+func TestEncodeWithoutTrace(t *testing.T) {
+	msg, err := Encode(FromEvent(testEvent(""), "/sync-relay"), ModeBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := msg.Headers["traceparent"]; ok {
+		t.Errorf("traceparent = %q, want it omitted", msg.Headers["traceparent"])
+	}
+}
+
+func TestTraceParent(t *testing.T) {
+	tests := []struct {
+		name    string
+		traceID string
+		want    string
+	}{
+		{"uuid maps directly", "4bf92f35-77b3-4da6-a3ce-929d0e0e4736", "00-4bf92f3577b34da6a3ce929d0e0e4736-"},
+		{"hex maps directly", "4BF92F3577B34DA6A3CE929D0E0E4736", "00-4bf92f3577b34da6a3ce929d0e0e4736-"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TraceParent(tt.traceID, "event-1")
+			if tt.want == "" {
+				if got != "" {
+					t.Fatalf("TraceParent = %q, want empty", got)
+				}
+				return
+			}
+			if len(got) != 55 || got[:36] != tt.want || got[52:] != "-01" {
+				t.Fatalf("TraceParent = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+
+	// all-zero and non-hex ids are hashed into a valid trace id
+	for _, id := range []string{"00000000-0000-0000-0000-000000000000", "job-42"} {
+		got := TraceParent(id, "event-1")
+		if len(got) != 55 || got[3:35] == "00000000000000000000000000000000" {
+			t.Errorf("TraceParent(%q) = %q, want a hashed trace id", id, got)
+		}
+		if got != TraceParent(id, "event-1") {
+			t.Errorf("TraceParent(%q) is not stable", id)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, s := range []string{"structured", "binary"} {
+		if mode, err := ParseMode(s); err != nil || string(mode) != s {
+			t.Errorf("ParseMode(%q) = %q, %v", s, mode, err)
+		}
+	}
+	for _, s := range []string{"", "Binary", "json"} {
+		if _, err := ParseMode(s); err == nil {
+			t.Errorf("ParseMode(%q) succeeded, want an error", s)
+		}
+	}
+}
@@ -4,9 +4,24 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/alexchny/sync-relay/internal/cloudevents"
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/google/uuid"
 )
 
+type SinkConfig struct {
+	Name           string
+	Tenants        []string
+	EventTypes     []string
+	MinAmountCents int
+	// block or async
+	Policy     string
+	BufferSize int
+}
+
 type Config struct {
 	Env        string
 	LogLevel   string
@@ -22,8 +37,61 @@ type Config struct {
 	PlaidSecret   string
 	PlaidEnv      string
 
+	PlaidWebhookKeyTTL time.Duration
+
 	WorkerConcurrency int
 	LockTTL           time.Duration
+
+	ReconciliationWindow time.Duration
+
+	// how long a removed item's data is kept before it is purged
+	ItemPurgeRetention time.Duration
+
+	JobMaxAttempts    int
+	JobRetryBaseDelay time.Duration
+	JobRetryMaxDelay  time.Duration
+
+	EventSinks           []SinkConfig
+	SinkAsyncMaxAttempts int
+	MetricsAddr          string
+	EventMaxPayloadBytes int
+	EventSource          string
+	CloudEventsMode      string
+	OutboxBatchSize      int
+	OutboxPollInterval   time.Duration
+	OutboxRetryMaxDelay  time.Duration
+	OutboxRetention      time.Duration
+	OutboxLeaseDuration  time.Duration
+	OutboxMaxAttempts    int
+
+	WebhookTimeout     time.Duration
+	WebhookMaxAttempts int
+	// allow subscriptions to loopback and private addresses, for local development only
+	WebhookAllowPrivateTargets bool
+
+	RedisStreamPrefix    string
+	RedisStreamPerTenant bool
+	RedisStreamMaxLen    int
+
+	KafkaBrokers []string
+	KafkaTopic   string
+	KafkaAcks    string
+
+	NatsURL           string
+	NatsSubjectPrefix string
+	NatsStream        string
+	NatsAutoProvision bool
+
+	PgNotifyEnabled bool
+
+	// end-user session tokens, enabled when a jwks url or file is set
+	JWTJWKSURL      string
+	JWTJWKSFile     string
+	JWTJWKSCacheTTL time.Duration
+	JWTIssuer       string
+	JWTAudience     string
+	JWTUserClaim    string
+	JWTTenantClaim  string
 }
 
 func Load() (*Config, error) {
@@ -42,8 +110,59 @@ func Load() (*Config, error) {
 		PlaidSecret:   getEnv("PLAID_SECRET", ""),
 		PlaidEnv:      getEnv("PLAID_ENV", "sandbox"),
 
+		PlaidWebhookKeyTTL: getEnvDuration("PLAID_WEBHOOK_KEY_TTL", 24*time.Hour),
+
 		WorkerConcurrency: getEnvInt("WORKER_CONCURRENCY", 5),
 		LockTTL:           getEnvDuration("LOCK_TTL", 2*time.Minute),
+
+		ReconciliationWindow: getEnvDuration("RECONCILIATION_WINDOW", 30*24*time.Hour),
+
+		ItemPurgeRetention: getEnvDuration("ITEM_PURGE_RETENTION", 7*24*time.Hour),
+
+		JobMaxAttempts:    getEnvInt("JOB_MAX_ATTEMPTS", 5),
+		JobRetryBaseDelay: getEnvDuration("JOB_RETRY_BASE_DELAY", 5*time.Second),
+		JobRetryMaxDelay:  getEnvDuration("JOB_RETRY_MAX_DELAY", 5*time.Minute),
+
+		EventSinks:           loadSinkConfigs(getEnvList("EVENT_SINKS", []string{getEnv("EVENT_SINK", "redis")})),
+		SinkAsyncMaxAttempts: getEnvInt("EVENT_SINK_ASYNC_MAX_ATTEMPTS", 10),
+		MetricsAddr:          getEnv("METRICS_ADDR", ""),
+		EventMaxPayloadBytes: getEnvInt("EVENT_MAX_PAYLOAD_BYTES", 256*1024),
+		EventSource:          getEnv("EVENT_SOURCE", "/sync-relay"),
+		CloudEventsMode:      getEnv("CLOUDEVENTS_MODE", "structured"),
+		OutboxBatchSize:      getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxPollInterval:   getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		OutboxRetryMaxDelay:  getEnvDuration("OUTBOX_RETRY_MAX_DELAY", 5*time.Minute),
+		OutboxRetention:      getEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+		OutboxLeaseDuration:  getEnvDuration("OUTBOX_LEASE_DURATION", 2*time.Minute),
+		OutboxMaxAttempts:    getEnvInt("OUTBOX_MAX_ATTEMPTS", 25),
+
+		WebhookTimeout:     getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 10),
+
+		WebhookAllowPrivateTargets: getEnvBool("WEBHOOK_ALLOW_PRIVATE_TARGETS", false),
+
+		RedisStreamPrefix:    getEnv("REDIS_STREAM_PREFIX", "sync-events"),
+		RedisStreamPerTenant: getEnvBool("REDIS_STREAM_PER_TENANT", false),
+		RedisStreamMaxLen:    getEnvInt("REDIS_STREAM_MAXLEN", 100000),
+
+		KafkaBrokers: getEnvList("KAFKA_BROKERS", []string{"localhost:9092"}),
+		KafkaTopic:   getEnv("KAFKA_TOPIC", "sync-events"),
+		KafkaAcks:    getEnv("KAFKA_ACKS", "all"),
+
+		NatsURL:           getEnv("NATS_URL", "nats://localhost:4222"),
+		NatsSubjectPrefix: getEnv("NATS_SUBJECT_PREFIX", "relay"),
+		NatsStream:        getEnv("NATS_STREAM", "RELAY_EVENTS"),
+		NatsAutoProvision: getEnvBool("NATS_AUTO_PROVISION", false),
+
+		PgNotifyEnabled: getEnvBool("PG_NOTIFY_ENABLED", false),
+
+		JWTJWKSURL:      getEnv("JWT_JWKS_URL", ""),
+		JWTJWKSFile:     getEnv("JWT_JWKS_FILE", ""),
+		JWTJWKSCacheTTL: getEnvDuration("JWT_JWKS_CACHE_TTL", 15*time.Minute),
+		JWTIssuer:       getEnv("JWT_ISSUER", ""),
+		JWTAudience:     getEnv("JWT_AUDIENCE", ""),
+		JWTUserClaim:    getEnv("JWT_USER_CLAIM", "sub"),
+		JWTTenantClaim:  getEnv("JWT_TENANT_CLAIM", "tenant_id"),
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -77,6 +196,173 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("WORKER_CONCURRENCY must be at least 1")
 	}
 
+	if c.LockTTL < 3*time.Second {
+		return fmt.Errorf("LOCK_TTL must be at least 3s")
+	}
+
+	if c.ItemPurgeRetention < 0 {
+		return fmt.Errorf("ITEM_PURGE_RETENTION must not be negative")
+	}
+
+	if c.JobMaxAttempts < 1 {
+		return fmt.Errorf("JOB_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.JobRetryBaseDelay <= 0 || c.JobRetryMaxDelay < c.JobRetryBaseDelay {
+		return fmt.Errorf("JOB_RETRY_BASE_DELAY must be positive and no larger than JOB_RETRY_MAX_DELAY")
+	}
+
+	if len(c.EventSinks) == 0 {
+		return fmt.Errorf("EVENT_SINKS must list at least one sink")
+	}
+	validEventSinks := map[string]bool{
+		"redis":         true,
+		"redis-streams": true,
+		"webhook":       true,
+		"kafka":         true,
+		"nats":          true,
+	}
+	seenSinks := map[string]bool{}
+	for _, sink := range c.EventSinks {
+		if !validEventSinks[sink.Name] {
+			return fmt.Errorf("invalid event sink: %s (must be redis, redis-streams, webhook, kafka, or nats)", sink.Name)
+		}
+		if seenSinks[sink.Name] {
+			return fmt.Errorf("event sink %s is listed more than once", sink.Name)
+		}
+		seenSinks[sink.Name] = true
+
+		if err := sink.validate(); err != nil {
+			return err
+		}
+	}
+	if c.SinkAsyncMaxAttempts < 1 {
+		return fmt.Errorf("EVENT_SINK_ASYNC_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.EventSource == "" {
+		return fmt.Errorf("EVENT_SOURCE is required")
+	}
+	if _, err := cloudevents.ParseMode(c.CloudEventsMode); err != nil {
+		return fmt.Errorf("invalid CLOUDEVENTS_MODE: %w", err)
+	}
+	if c.OutboxBatchSize < 1 {
+		return fmt.Errorf("OUTBOX_BATCH_SIZE must be at least 1")
+	}
+	if c.OutboxPollInterval <= 0 {
+		return fmt.Errorf("OUTBOX_POLL_INTERVAL must be positive")
+	}
+	if c.OutboxLeaseDuration <= 0 {
+		return fmt.Errorf("OUTBOX_LEASE_DURATION must be positive")
+	}
+	if c.OutboxMaxAttempts < 1 {
+		return fmt.Errorf("OUTBOX_MAX_ATTEMPTS must be at least 1")
+	}
+
+	if c.WebhookTimeout <= 0 {
+		return fmt.Errorf("WEBHOOK_TIMEOUT must be positive")
+	}
+	if c.WebhookMaxAttempts < 1 {
+		return fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be at least 1")
+	}
+
+	if c.RedisStreamPrefix == "" {
+		return fmt.Errorf("REDIS_STREAM_PREFIX is required")
+	}
+	if c.RedisStreamMaxLen < 1 {
+		return fmt.Errorf("REDIS_STREAM_MAXLEN must be at least 1")
+	}
+
+	if c.HasSink("kafka") {
+		if len(c.KafkaBrokers) == 0 {
+			return fmt.Errorf("KAFKA_BROKERS is required when EVENT_SINK is kafka")
+		}
+		if c.KafkaTopic == "" {
+			return fmt.Errorf("KAFKA_TOPIC is required when EVENT_SINK is kafka")
+		}
+	}
+	if c.HasSink("nats") {
+		if c.NatsURL == "" || c.NatsSubjectPrefix == "" || c.NatsStream == "" {
+			return fmt.Errorf("NATS_URL, NATS_SUBJECT_PREFIX and NATS_STREAM are required when EVENT_SINK is nats")
+		}
+	}
+	validKafkaAcks := map[string]bool{
+		"all":    true,
+		"leader": true,
+		"none":   true,
+	}
+	if !validKafkaAcks[c.KafkaAcks] {
+		return fmt.Errorf("invalid KAFKA_ACKS: %s (must be all, leader, or none)", c.KafkaAcks)
+	}
+
+	if c.ReconciliationWindow < 24*time.Hour {
+		return fmt.Errorf("RECONCILIATION_WINDOW must be at least 24h")
+	}
+
+	if c.JWTJWKSURL != "" && c.JWTJWKSFile != "" {
+		return fmt.Errorf("set only one of JWT_JWKS_URL and JWT_JWKS_FILE")
+	}
+	if c.JWTEnabled() {
+		if c.JWTUserClaim == "" || c.JWTTenantClaim == "" {
+			return fmt.Errorf("JWT_USER_CLAIM and JWT_TENANT_CLAIM are required when jwt auth is enabled")
+		}
+		if c.JWTJWKSCacheTTL <= 0 {
+			return fmt.Errorf("JWT_JWKS_CACHE_TTL must be positive")
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) JWTEnabled() bool {
+	return c.JWTJWKSURL != "" || c.JWTJWKSFile != ""
+}
+
+func (c *Config) HasSink(name string) bool {
+	for _, sink := range c.EventSinks {
+		if sink.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// per-sink settings live under EVENT_SINK_<NAME>_*, e.g. EVENT_SINK_REDIS_STREAMS_POLICY
+func loadSinkConfigs(names []string) []SinkConfig {
+	sinks := make([]SinkConfig, 0, len(names))
+	for _, name := range names {
+		prefix := "EVENT_SINK_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		sinks = append(sinks, SinkConfig{
+			Name:           name,
+			Tenants:        getEnvList(prefix+"TENANTS", nil),
+			EventTypes:     getEnvList(prefix+"EVENT_TYPES", nil),
+			MinAmountCents: getEnvInt(prefix+"MIN_AMOUNT_CENTS", 0),
+			Policy:         getEnv(prefix+"POLICY", "block"),
+			BufferSize:     getEnvInt(prefix+"BUFFER_SIZE", 10000),
+		})
+	}
+	return sinks
+}
+
+func (s SinkConfig) validate() error {
+	for _, tenant := range s.Tenants {
+		if _, err := uuid.Parse(tenant); err != nil {
+			return fmt.Errorf("invalid tenant id for sink %s: %s", s.Name, tenant)
+		}
+	}
+	// a typo would make the filter drop every event without a word
+	for _, eventType := range s.EventTypes {
+		if !domain.EventType(eventType).IsKnown() {
+			return fmt.Errorf("unknown event type for sink %s: %s", s.Name, eventType)
+		}
+	}
+	if s.MinAmountCents < 0 {
+		return fmt.Errorf("min amount for sink %s must not be negative", s.Name)
+	}
+	if s.Policy != "block" && s.Policy != "async" {
+		return fmt.Errorf("invalid policy for sink %s: %s (must be block or async)", s.Name, s.Policy)
+	}
+	if s.Policy == "async" && s.BufferSize < 1 {
+		return fmt.Errorf("buffer size for sink %s must be at least 1", s.Name)
+	}
 	return nil
 }
 
@@ -99,6 +385,33 @@ func getEnvInt(key string, fallback int) int {
 	return val
 }
 
+func getEnvBool(key string, fallback bool) bool {
+	valStr, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	val, err := strconv.ParseBool(valStr)
+	if err != nil {
+		panic(fmt.Sprintf("env var %s must be a boolean, got: %s", key, valStr))
+	}
+	return val
+}
+
+// comma separated, blank entries are dropped
+func getEnvList(key string, fallback []string) []string {
+	valStr, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	var vals []string
+	for _, v := range strings.Split(valStr, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
+
 func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	valStr, exists := os.LookupEnv(key)
 	if !exists {
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig loads the defaults with just the required settings filled in
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	t.Setenv("DATABASE_URL", "postgres://localhost/relay")
+	t.Setenv("PLAID_CLIENT_ID", "client")
+	t.Setenv("PLAID_SECRET", "secret")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("defaults don't validate: %v", err)
+	}
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr string
+	}{
+		{name: "defaults", mutate: func(c *Config) {}},
+		{
+			name:    "missing database url",
+			mutate:  func(c *Config) { c.DatabaseURL = "" },
+			wantErr: "DATABASE_URL is required",
+		},
+		{
+			name:    "missing plaid secret",
+			mutate:  func(c *Config) { c.PlaidSecret = "" },
+			wantErr: "PLAID_SECRET is required",
+		},
+		{
+			name:    "unknown plaid env",
+			mutate:  func(c *Config) { c.PlaidEnv = "staging" },
+			wantErr: "invalid PLAID_ENV",
+		},
+		{
+			name:    "short lock ttl",
+			mutate:  func(c *Config) { c.LockTTL = time.Second },
+			wantErr: "LOCK_TTL must be at least 3s",
+		},
+		{
+			name:    "retry base above max",
+			mutate:  func(c *Config) { c.JobRetryBaseDelay = time.Hour },
+			wantErr: "JOB_RETRY_BASE_DELAY",
+		},
+		{
+			name:    "no sinks",
+			mutate:  func(c *Config) { c.EventSinks = nil },
+			wantErr: "EVENT_SINKS must list at least one sink",
+		},
+		{
+			name:    "unknown sink",
+			mutate:  func(c *Config) { c.EventSinks = []SinkConfig{{Name: "sqs", Policy: "block"}} },
+			wantErr: "invalid event sink: sqs",
+		},
+		{
+			name: "duplicate sink",
+			mutate: func(c *Config) {
+				c.EventSinks = []SinkConfig{{Name: "redis", Policy: "block"}, {Name: "redis", Policy: "block"}}
+			},
+			wantErr: "listed more than once",
+		},
+		{
+			name: "bad sink tenant",
+			mutate: func(c *Config) {
+				c.EventSinks = []SinkConfig{{Name: "redis", Policy: "block", Tenants: []string{"acme"}}}
+			},
+			wantErr: "invalid tenant id for sink redis",
+		},
+		{
+			name: "unknown sink event type",
+			mutate: func(c *Config) {
+				c.EventSinks = []SinkConfig{{Name: "redis", Policy: "block", EventTypes: []string{"transactions.added", "transaction.removed"}}}
+			},
+			wantErr: "unknown event type for sink redis: transaction.removed",
+		},
+		{
+			name: "known sink event types",
+			mutate: func(c *Config) {
+				c.EventSinks = []SinkConfig{{Name: "redis", Policy: "block", EventTypes: []string{"transactions.added", "item.error"}}}
+			},
+		},
+		{
+			name:    "bad sink policy",
+			mutate:  func(c *Config) { c.EventSinks = []SinkConfig{{Name: "redis", Policy: "drop"}} },
+			wantErr: "invalid policy for sink redis",
+		},
+		{
+			name:    "async sink without buffer",
+			mutate:  func(c *Config) { c.EventSinks = []SinkConfig{{Name: "redis", Policy: "async"}} },
+			wantErr: "buffer size for sink redis",
+		},
+		{
+			name:    "unknown cloudevents mode",
+			mutate:  func(c *Config) { c.CloudEventsMode = "batched" },
+			wantErr: "invalid CLOUDEVENTS_MODE",
+		},
+		{
+			name:    "zero outbox lease",
+			mutate:  func(c *Config) { c.OutboxLeaseDuration = 0 },
+			wantErr: "OUTBOX_LEASE_DURATION must be positive",
+		},
+		{
+			name:    "zero outbox max attempts",
+			mutate:  func(c *Config) { c.OutboxMaxAttempts = 0 },
+			wantErr: "OUTBOX_MAX_ATTEMPTS must be at least 1",
+		},
+		{
+			name:    "kafka without brokers",
+			mutate:  func(c *Config) { c.EventSinks = []SinkConfig{{Name: "kafka", Policy: "block"}}; c.KafkaBrokers = nil },
+			wantErr: "KAFKA_BROKERS is required",
+		},
+		{
+			name:    "short reconciliation window",
+			mutate:  func(c *Config) { c.ReconciliationWindow = time.Hour },
+			wantErr: "RECONCILIATION_WINDOW must be at least 24h",
+		},
+		{
+			name: "both jwks sources",
+			mutate: func(c *Config) {
+				c.JWTJWKSURL = "https://auth.example.com/.well-known/jwks.json"
+				c.JWTJWKSFile = "/etc/relay/jwks.json"
+			},
+			wantErr: "set only one of JWT_JWKS_URL and JWT_JWKS_FILE",
+		},
+		{
+			name: "jwt without tenant claim",
+			mutate: func(c *Config) {
+				c.JWTJWKSFile = "/etc/relay/jwks.json"
+				c.JWTTenantClaim = ""
+			},
+			wantErr: "JWT_USER_CLAIM and JWT_TENANT_CLAIM are required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tt.mutate(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventTypeTransactionsAdded    EventType = "transactions.added"
+	EventTypeTransactionsModified EventType = "transactions.modified"
+	EventTypeTransactionsRemoved  EventType = "transactions.removed"
+
+	EventTypeItemError             EventType = "item.error"
+	EventTypeItemLoginRepaired     EventType = "item.login_repaired"
+	EventTypeItemPendingExpiration EventType = "item.pending_expiration"
+	EventTypeItemPermissionRevoked EventType = "item.permission_revoked"
+	EventTypeItemRemoved           EventType = "item.removed"
+)
+
+func (t EventType) IsKnown() bool {
+	switch t {
+	case EventTypeTransactionsAdded,
+		EventTypeTransactionsModified,
+		EventTypeTransactionsRemoved,
+		EventTypeItemError,
+		EventTypeItemLoginRepaired,
+		EventTypeItemPendingExpiration,
+		EventTypeItemPermissionRevoked,
+		EventTypeItemRemoved:
+		return true
+	}
+	return false
+}
+
+// Category is the part before the dot, "transactions" or "item"
+func (t EventType) Category() string {
+	category, _, _ := strings.Cut(string(t), ".")
+	return category
+}
+
+// bump when the payload shape changes in a way consumers must handle
+const EventSchemaVersion = 3
+
+type PayloadMode string
+
+const (
+	// changes carry full transaction values
+	PayloadModeFull PayloadMode = "full"
+	// changes carry only ids, the page was too large to inline
+	PayloadModeReference PayloadMode = "reference"
+)
+
+type ChangeKind string
+
+const (
+	ChangeKindAdded    ChangeKind = "added"
+	ChangeKindModified ChangeKind = "modified"
+	ChangeKindRemoved  ChangeKind = "removed"
+)
+
+type TransactionSnapshot struct {
+	PendingTransactionID *string           `json:"pending_transaction_id,omitempty"`
+	AmountCents          int64             `json:"amount_cents"`
+	CurrencyCode         string            `json:"currency_code"`
+	Date                 string            `json:"date"`
+	MerchantName         string            `json:"merchant_name"`
+	Status               TransactionStatus `json:"status"`
+}
+
+func NewTransactionSnapshot(tx *Transaction) *TransactionSnapshot {
+	return &TransactionSnapshot{
+		PendingTransactionID: tx.PlaidPendingID,
+		AmountCents:          tx.AmountCents,
+		CurrencyCode:         tx.CurrencyCode,
+		Date:                 tx.Date.Format("2006-01-02"),
+		MerchantName:         tx.MerchantName,
+		Status:               tx.Status,
+	}
+}
+
+type TransactionChange struct {
+	Kind          ChangeKind           `json:"kind"`
+	TransactionID string               `json:"transaction_id"`
+	Current       *TransactionSnapshot `json:"current,omitempty"`
+	Previous      *TransactionSnapshot `json:"previous,omitempty"`
+}
+
+type TransactionChangesPayload struct {
+	SchemaVersion int                 `json:"schema_version"`
+	ItemID        string              `json:"item_id"`
+	Mode          PayloadMode         `json:"mode"`
+	Count         int                 `json:"count"`
+	Changes       []TransactionChange `json:"changes"`
+}
+
+type ItemLifecyclePayload struct {
+	SchemaVersion    int        `json:"schema_version"`
+	ItemID           string     `json:"item_id"`
+	Status           SyncStatus `json:"status"`
+	ErrorCode        string     `json:"error_code,omitempty"`
+	ErrorMessage     string     `json:"error_message,omitempty"`
+	ConsentExpiresAt *time.Time `json:"consent_expires_at,omitempty"`
+	PurgeAfter       *time.Time `json:"purge_after,omitempty"`
+}
+
+type Event struct {
+	ID       uuid.UUID
+	TenantID uuid.UUID
+	ItemID   uuid.UUID
+	Type     EventType
+	Payload  []byte
+	TraceID  string
+
+	CreatedAt time.Time
+}
+
+func NewEvent(tenantID, itemID uuid.UUID, eventType EventType, payload []byte, traceID string) *Event {
+	return &Event{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		ItemID:    itemID,
+		Type:      eventType,
+		Payload:   payload,
+		TraceID:   traceID,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// an event waiting in the outbox to be relayed
+type OutboxEntry struct {
+	Sequence      int64
+	Event         *Event
+	Attempts      int
+	NextAttemptAt time.Time
+	// sinks that already took the event on an earlier attempt
+	DeliveredSinks []string
+}
@@ -12,11 +12,29 @@ const (
 	SyncStatusActive    SyncStatus = "active"
 	SyncStatusError     SyncStatus = "error"
 	SyncStatusReSyncing SyncStatus = "resyncing"
+	// the user withdrew consent, the access token is gone
+	SyncStatusRevoked SyncStatus = "revoked"
+	// disconnected by us, the row is purged after PurgeAfter
+	SyncStatusRemoved SyncStatus = "removed"
 )
 
+func (s SyncStatus) IsKnown() bool {
+	switch s {
+	case SyncStatusActive,
+		SyncStatusError,
+		SyncStatusReSyncing,
+		SyncStatusRevoked,
+		SyncStatusRemoved:
+		return true
+	}
+	return false
+}
+
 type Item struct {
-	ID             uuid.UUID
-	TenantID       uuid.UUID
+	ID       uuid.UUID
+	TenantID uuid.UUID
+	// the tenant's own id for the person who linked the item
+	EndUserID      string
 	PlaidItemID    string
 	AccessTokenEnc string
 
@@ -24,6 +42,22 @@ type Item struct {
 	SyncStatus   SyncStatus
 	LastSyncedAt *time.Time
 	ErrorMessage string
+	// plaid error code, e.g. ITEM_LOGIN_REQUIRED
+	ErrorCode     string
+	ErrorCategory ErrorCategory
+	// start of the current run of failures, reset by a successful sync
+	ErrorFirstSeenAt *time.Time
+	ErrorRetryCount  int
+
+	// when the user's consent lapses and the item stops updating
+	ConsentExpiresAt *time.Time
+
+	// a removed item's row and transactions are deleted after this
+	PurgeAfter *time.Time
+
+	// plaid has delivered the first 30 days / the full history
+	InitialUpdateComplete    bool
+	HistoricalUpdateComplete bool
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -33,6 +67,10 @@ func (i *Item) CanSync() bool {
 	return i.SyncStatus == SyncStatusActive || i.SyncStatus == SyncStatusReSyncing
 }
 
+func (i *Item) IsResyncing() bool {
+	return i.SyncStatus == SyncStatusReSyncing
+}
+
 func (i *Item) HasError() bool {
 	return i.SyncStatus == SyncStatusError
 }
@@ -43,24 +81,63 @@ func (i *Item) MarkResyncing() {
 	i.UpdatedAt = time.Now()
 }
 
+func (i *Item) IsRevoked() bool {
+	return i.SyncStatus == SyncStatusRevoked
+}
+
+func (i *Item) IsRemoved() bool {
+	return i.SyncStatus == SyncStatusRemoved
+}
+
 func (i *Item) MarkActive() {
 	i.SyncStatus = SyncStatusActive
-	i.ErrorMessage = ""
+	i.clearError()
+	i.UpdatedAt = time.Now()
+}
+
+func (i *Item) SetConsentExpiration(expiresAt time.Time) {
+	i.ConsentExpiresAt = &expiresAt
+	i.UpdatedAt = time.Now()
+}
+
+func (i *Item) MarkRevoked() {
+	i.SyncStatus = SyncStatusRevoked
+	i.AccessTokenEnc = ""
 	i.UpdatedAt = time.Now()
 }
 
-func (i *Item) MarkError(err error) {
+func (i *Item) MarkRemoved(purgeAfter time.Time) {
+	i.SyncStatus = SyncStatusRemoved
+	i.AccessTokenEnc = ""
+	i.PurgeAfter = &purgeAfter
+	i.UpdatedAt = time.Now()
+}
+
+func (i *Item) MarkError(e ItemError) {
+	now := time.Now()
+
 	i.SyncStatus = SyncStatusError
-	if err != nil {
-		i.ErrorMessage = err.Error()
+	i.ErrorCode = e.Code
+	i.ErrorCategory = e.Category
+	i.ErrorMessage = e.Message
+	if i.ErrorFirstSeenAt == nil {
+		i.ErrorFirstSeenAt = &now
 	}
-	i.UpdatedAt = time.Now()
+	i.UpdatedAt = now
+}
+
+func (i *Item) clearError() {
+	i.ErrorMessage = ""
+	i.ErrorCode = ""
+	i.ErrorCategory = ""
+	i.ErrorFirstSeenAt = nil
+	i.ErrorRetryCount = 0
 }
 
 func (i *Item) UpdateSuccess(cursor string) {
 	i.SyncStatus = SyncStatusActive
 	i.NextCursor = cursor
-	i.ErrorMessage = ""
+	i.clearError()
 
 	now := time.Now()
 	i.LastSyncedAt = &now
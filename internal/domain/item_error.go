@@ -0,0 +1,64 @@
+package domain
+
+type ErrorCategory string
+
+const (
+	// the end user has to re-authenticate or grant access in link
+	ErrorCategoryUserAction ErrorCategory = "user_action"
+	// plaid or the institution is having trouble, retrying will fix it
+	ErrorCategoryTransient ErrorCategory = "transient"
+	// our credentials, products or request are wrong
+	ErrorCategoryConfig  ErrorCategory = "config"
+	ErrorCategoryUnknown ErrorCategory = "unknown"
+)
+
+type ItemError struct {
+	Code     string
+	Category ErrorCategory
+	Message  string
+}
+
+// ClassifyPlaidErrorCode groups plaid error codes by who has to act on them
+func ClassifyPlaidErrorCode(code string) ErrorCategory {
+	switch code {
+	case "ITEM_LOGIN_REQUIRED",
+		"ITEM_LOCKED",
+		"ITEM_NOT_FOUND",
+		"INVALID_ACCESS_TOKEN",
+		"USER_SETUP_REQUIRED",
+		"INVALID_CREDENTIALS",
+		"INSUFFICIENT_CREDENTIALS",
+		"INVALID_MFA",
+		"INVALID_SEND_METHOD",
+		"MFA_NOT_SUPPORTED",
+		"NO_ACCOUNTS",
+		"ACCESS_NOT_GRANTED",
+		"PENDING_EXPIRATION",
+		"USER_INPUT_TIMEOUT":
+		return ErrorCategoryUserAction
+	case "INSTITUTION_DOWN",
+		"INSTITUTION_NOT_RESPONDING",
+		"INSTITUTION_NOT_AVAILABLE",
+		"INSTITUTION_REGISTRATION_REQUIRED",
+		"INTERNAL_SERVER_ERROR",
+		"PLANNED_MAINTENANCE",
+		"RATE_LIMIT_EXCEEDED",
+		"PRODUCT_NOT_READY",
+		"TRANSACTIONS_SYNC_MUTATION_DURING_PAGINATION",
+		"TRANSACTIONS_SYNC_MUTATION_LIMIT_EXCEEDED":
+		return ErrorCategoryTransient
+	case "INVALID_API_KEYS",
+		"UNAUTHORIZED_ENVIRONMENT",
+		"INVALID_PRODUCT",
+		"PRODUCTS_NOT_SUPPORTED",
+		"PRODUCT_NOT_ENABLED",
+		"INSTITUTION_NO_LONGER_SUPPORTED",
+		"INVALID_FIELD",
+		"INVALID_BODY",
+		"MISSING_FIELDS",
+		"INVALID_CONFIGURATION",
+		"ADDITION_LIMIT":
+		return ErrorCategoryConfig
+	}
+	return ErrorCategoryUnknown
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "testing"
+
+func TestClassifyPlaidErrorCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want ErrorCategory
+	}{
+		{"ITEM_LOGIN_REQUIRED", ErrorCategoryUserAction},
+		{"INVALID_ACCESS_TOKEN", ErrorCategoryUserAction},
+		{"PENDING_EXPIRATION", ErrorCategoryUserAction},
+		{"INSTITUTION_DOWN", ErrorCategoryTransient},
+		{"RATE_LIMIT_EXCEEDED", ErrorCategoryTransient},
+		{"TRANSACTIONS_SYNC_MUTATION_DURING_PAGINATION", ErrorCategoryTransient},
+		{"INVALID_API_KEYS", ErrorCategoryConfig},
+		{"PRODUCT_NOT_ENABLED", ErrorCategoryConfig},
+		{"SOMETHING_NEW", ErrorCategoryUnknown},
+		{"", ErrorCategoryUnknown},
+		{"item_login_required", ErrorCategoryUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := ClassifyPlaidErrorCode(tt.code); got != tt.want {
+				t.Errorf("ClassifyPlaidErrorCode(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReconciliationReport struct {
+	ID          uuid.UUID
+	ItemID      uuid.UUID
+	WindowStart time.Time
+	WindowEnd   time.Time
+
+	PlaidCount      int
+	StoredCount     int
+	MissingCount    int
+	MismatchedCount int
+	PhantomCount    int
+
+	// rows we have that plaid no longer returns for the window
+	PhantomTransactionIDs []string
+
+	CreatedAt time.Time
+}
+
+func (r *ReconciliationReport) HasDrift() bool {
+	return r.MissingCount > 0 || r.MismatchedCount > 0 || r.PhantomCount > 0
+}
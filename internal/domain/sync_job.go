@@ -12,7 +12,18 @@ const (
 )
 
 type SyncJob struct {
+	ID      string
 	ItemID  uuid.UUID
 	JobType SyncJobType
 	TraceID string
+
+	Attempts  int
+	LastError string
+}
+
+func (j *SyncJob) RecordFailure(err error) {
+	j.Attempts++
+	if err != nil {
+		j.LastError = err.Error()
+	}
 }
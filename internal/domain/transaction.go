@@ -18,6 +18,8 @@ type Transaction struct {
 	ItemID             uuid.UUID
 	PlaidTransactionID string
 	PlaidPendingID     *string
+	// plaid's id for the account within the item
+	AccountID string
 
 	AmountCents  int64
 	CurrencyCode string
@@ -27,6 +29,8 @@ type Transaction struct {
 
 	IsRemoved  bool
 	RawPayload []byte
+	// when reconciliation found plaid no longer returns the row, nil otherwise
+	PhantomAt *time.Time
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -40,12 +44,31 @@ func (t *Transaction) IsPending() bool {
 	return t.Status == TransactionStatusPending
 }
 
+// reports whether incoming carries the same values as the stored row
+func (t *Transaction) Matches(incoming Transaction) bool {
+	if t.IsRemoved {
+		return false
+	}
+
+	samePendingID := (t.PlaidPendingID == nil && incoming.PlaidPendingID == nil) ||
+		(t.PlaidPendingID != nil && incoming.PlaidPendingID != nil && *t.PlaidPendingID == *incoming.PlaidPendingID)
+
+	return samePendingID &&
+		t.AccountID == incoming.AccountID &&
+		t.AmountCents == incoming.AmountCents &&
+		t.CurrencyCode == incoming.CurrencyCode &&
+		t.Date.Equal(incoming.Date) &&
+		t.MerchantName == incoming.MerchantName &&
+		t.Status == incoming.Status
+}
+
 func (t *Transaction) MarkRemoved() {
 	t.IsRemoved = true
 	t.UpdatedAt = time.Now()
 }
 
 func (t *Transaction) UpdateTransaction(incoming Transaction) {
+	t.AccountID = incoming.AccountID
 	t.AmountCents = incoming.AmountCents
 	t.CurrencyCode = incoming.CurrencyCode
 	t.Date = incoming.Date
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransactionMatches(t *testing.T) {
+	pendingID := "pending-1"
+	stored := Transaction{
+		PlaidTransactionID: "tx-1",
+		PlaidPendingID:     &pendingID,
+		AccountID:          "acc-1",
+		AmountCents:        1250,
+		CurrencyCode:       "USD",
+		Date:               time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		MerchantName:       "Coffee",
+		Status:             TransactionStatusPosted,
+	}
+
+	otherPendingID := "pending-2"
+	tests := []struct {
+		name   string
+		change func(tx *Transaction)
+		want   bool
+	}{
+		{"identical", func(tx *Transaction) {}, true},
+		{"moved to another account", func(tx *Transaction) { tx.AccountID = "acc-2" }, false},
+		{"amount changed", func(tx *Transaction) { tx.AmountCents = 1300 }, false},
+		{"date changed", func(tx *Transaction) { tx.Date = tx.Date.AddDate(0, 0, 1) }, false},
+		{"pending id changed", func(tx *Transaction) { tx.PlaidPendingID = &otherPendingID }, false},
+		{"pending id dropped", func(tx *Transaction) { tx.PlaidPendingID = nil }, false},
+		{"status changed", func(tx *Transaction) { tx.Status = TransactionStatusPending }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incoming := stored
+			tt.change(&incoming)
+
+			if got := stored.Matches(incoming); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemovedTransactionNeverMatches(t *testing.T) {
+	stored := Transaction{PlaidTransactionID: "tx-1", IsRemoved: true}
+
+	if stored.Matches(stored) {
+		t.Fatal("removed row matched, it would never be restored")
+	}
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookSubscription struct {
+	ID         uuid.UUID
+	TenantID   uuid.UUID
+	URL        string
+	EventTypes []EventType
+	// shared with the tenant, signs every delivery
+	Secret    string
+	CreatedAt time.Time
+}
+
+func (s *WebhookSubscription) Wants(eventType EventType) bool {
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one attempt to deliver an event to a subscription
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	Attempt        int
+	// zero when the endpoint never answered
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+	Delivered  bool
+	CreatedAt  time.Time
+}
@@ -0,0 +1,82 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false}, // cloud metadata
+		{"fe80::1", false},
+		{"100.64.0.1", false},
+		{"198.18.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a00:1", false}, // nat64 for 10.0.0.1
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := IsPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("IsPublic(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckHostLiteral(t *testing.T) {
+	tests := []struct {
+		host    string
+		wantErr bool
+	}{
+		{"1.1.1.1", false},
+		{"127.0.0.1", true},
+		{"169.254.169.254", true},
+		{"::1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			err := CheckHost(context.Background(), tt.host)
+			if tt.wantErr != errors.Is(err, ErrForbiddenAddress) {
+				t.Errorf("CheckHost(%s) = %v, wantErr %v", tt.host, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDialerRefusesLoopback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	conn, err := Dialer(time.Second).DialContext(context.Background(), "tcp", ln.Addr().String())
+	if err == nil {
+		_ = conn.Close()
+		t.Fatal("dialed a loopback address")
+	}
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("error = %v, want ErrForbiddenAddress", err)
+	}
+}
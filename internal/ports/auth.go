@@ -0,0 +1,20 @@
+package ports
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidSessionToken = errors.New("invalid session token")
+
+// EndUserIdentity is what an identity provider's session token vouches for
+type EndUserIdentity struct {
+	TenantID  uuid.UUID
+	EndUserID string
+}
+
+type SessionTokenVerifier interface {
+	VerifySessionToken(ctx context.Context, token string) (*EndUserIdentity, error)
+}
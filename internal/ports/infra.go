@@ -2,16 +2,33 @@ package ports
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/alexchny/sync-relay/internal/domain"
-	"github.com/google/uuid"
 )
 
+var ErrLockBusy = errors.New("lock is already acquired")
+
+var ErrLockLost = errors.New("lock was lost before work finished")
+
+type Lock interface {
+	// monotonically increasing per key, newer holders always get a larger token
+	FencingToken() int64
+	// extends the ttl, returns ErrLockLost if someone else holds the key now
+	Refresh(ctx context.Context) error
+	Release() error
+}
+
 type DistributedLock interface {
-	Acquire(ctx context.Context, key string, ttl time.Duration) (release func() error, err error)
+	Acquire(ctx context.Context, key string, ttl time.Duration) (Lock, error)
+}
+
+type LockInspector interface {
+	// which of the keys currently have a holder
+	Held(ctx context.Context, keys ...string) (map[string]bool, error)
 }
 
 type EventPublisher interface {
-	PublishSyncEvents(ctx context.Context, itemID uuid.UUID, added, modified []*domain.Transaction, removedIDs []string) error
+	Publish(ctx context.Context, events ...*domain.Event) error
 }
@@ -2,8 +2,11 @@ package ports
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/alexchny/sync-relay/internal/domain"
 )
@@ -14,6 +17,26 @@ var ErrUserActionRequired = errors.New("user action required")
 
 var ErrInvalidToken = errors.New("invalid or expired access token")
 
+var ErrPlaidUnavailable = errors.New("plaid temporarily unavailable")
+
+var ErrVerificationKeyNotFound = errors.New("webhook verification key not found")
+
+// PlaidAPIError is an error response from plaid. it unwraps to the port error
+// the sync flow reacts to, if there is one.
+type PlaidAPIError struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *PlaidAPIError) Error() string {
+	return fmt.Sprintf("plaid error %s: %s", e.Code, e.Message)
+}
+
+func (e *PlaidAPIError) Unwrap() error {
+	return e.Err
+}
+
 type SyncResponse struct {
 	Added    []*domain.Transaction
 	Modified []*domain.Transaction
@@ -32,18 +55,51 @@ type WebhookPayload struct {
 	WebhookType string `json:"webhook_type"`
 	WebhookCode string `json:"webhook_code"`
 	ItemID      string `json:"item_id"`
-	Error       *struct {
+
+	// SYNC_UPDATES_AVAILABLE
+	InitialUpdateComplete    *bool `json:"initial_update_complete,omitempty"`
+	HistoricalUpdateComplete *bool `json:"historical_update_complete,omitempty"`
+
+	// legacy INITIAL_UPDATE, HISTORICAL_UPDATE, DEFAULT_UPDATE and TRANSACTIONS_REMOVED
+	NewTransactions int `json:"new_transactions,omitempty"`
+	// informational only, the sync feed is what applies removals
+	RemovedTransactions []string `json:"removed_transactions,omitempty"`
+
+	// ITEM PENDING_EXPIRATION and WEBHOOK_UPDATE_ACKNOWLEDGED
+	ConsentExpirationTime *time.Time `json:"consent_expiration_time,omitempty"`
+	NewWebhookURL         string     `json:"new_webhook_url,omitempty"`
+
+	Error *struct {
 		ErrorMessage string `json:"error_message"`
 		ErrorCode    string `json:"error_code"`
 	} `json:"error,omitempty"`
 }
 
+type WebhookVerificationKey struct {
+	KeyID     string
+	PublicKey *ecdsa.PublicKey
+	CreatedAt time.Time
+	ExpiredAt *time.Time
+}
+
+func (k *WebhookVerificationKey) IsExpired(now time.Time) bool {
+	return k.ExpiredAt != nil && !now.Before(*k.ExpiredAt)
+}
+
 type PlaidClient interface {
 	FetchSyncUpdates(ctx context.Context, accessToken, cursor string) (*SyncResponse, error)
+	FetchTransactions(ctx context.Context, accessToken string, start, end time.Time) ([]*domain.Transaction, error)
 	ExchangePublicToken(ctx context.Context, publicToken string) (*TokenExchangeResponse, error)
 	CreateLinkToken(ctx context.Context, userID string) (string, error)
+	CreateUpdateLinkToken(ctx context.Context, userID, accessToken string) (string, error)
+	// invalidates the access token, an item plaid no longer knows counts as removed
+	RemoveItem(ctx context.Context, accessToken string) error
 }
 
 type WebhookVerifier interface {
 	VerifyWebhook(ctx context.Context, r *http.Request) (*WebhookPayload, error)
 }
+
+type WebhookKeyStore interface {
+	GetVerificationKey(ctx context.Context, keyID string) (*WebhookVerificationKey, error)
+}
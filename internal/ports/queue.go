@@ -2,10 +2,36 @@ package ports
 
 import (
 	"context"
+	"time"
 
 	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/google/uuid"
 )
 
 type JobQueue interface {
 	Enqueue(ctx context.Context, job *domain.SyncJob) error
+	// moves the next job into consumerID's in-flight list, nil job on timeout
+	Dequeue(ctx context.Context, consumerID string, timeout time.Duration) (*domain.SyncJob, error)
+	// job is done, drop it from the in-flight list
+	Ack(ctx context.Context, job *domain.SyncJob) error
+	// job was not processed, hand it back to the queue after delay
+	Nack(ctx context.Context, job *domain.SyncJob, delay time.Duration) error
+	// job failed for good, park it where it can be inspected and replayed
+	DeadLetter(ctx context.Context, job *domain.SyncJob) error
+}
+
+// JobQueueMaintainer is the housekeeping a worker process runs next to its consumers
+type JobQueueMaintainer interface {
+	// marks the consumers alive so their in-flight jobs aren't reclaimed
+	Heartbeat(ctx context.Context, consumerIDs ...string) error
+	// moves delayed jobs whose backoff has elapsed onto the queue
+	PromoteDue(ctx context.Context) (int, error)
+	// hands in-flight jobs of consumers that stopped heartbeating back to the queue
+	RequeueOrphaned(ctx context.Context) (int, error)
+}
+
+type JobQueueInspector interface {
+	// which of the given items have a job that hasn't been acked or dead-lettered
+	// yet, whether it's waiting, backing off before a retry or running
+	QueuedItems(ctx context.Context, itemIDs ...uuid.UUID) (map[uuid.UUID]struct{}, error)
 }
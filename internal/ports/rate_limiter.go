@@ -2,9 +2,12 @@ package ports
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+var ErrRateLimited = errors.New("rate limit wait timed out")
+
 type RateLimiter interface {
 	Allow(ctx context.Context, key string) (bool, time.Duration, error)
 	Wait(ctx context.Context, key string) error
@@ -3,6 +3,7 @@ package ports
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/alexchny/sync-relay/internal/domain"
 	"github.com/google/uuid"
@@ -10,17 +11,162 @@ import (
 
 var ErrItemAlreadyExists = errors.New("item already exists")
 
+var ErrItemNotFound = errors.New("item not found")
+
+var ErrStaleFencingToken = errors.New("fencing token is older than the last write")
+
+// the item was revoked or removed while a sync was running
+var ErrItemInactive = errors.New("item is no longer active")
+
+// the item moved to a status the write doesn't apply to since it was read
+var ErrItemStateChanged = errors.New("item status changed concurrently")
+
+var ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+
+var ErrTenantNotFound = errors.New("tenant not found")
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
 type ItemRepository interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*domain.Item, error)
 	GetByPlaidItemID(ctx context.Context, plaidItemID string) (*domain.Item, error)
 	Create(ctx context.Context, item *domain.Item) error
-	UpdateSuccess(ctx context.Context, id uuid.UUID, cursor string) error
-	MarkResyncing(ctx context.Context, id uuid.UUID) error
-	MarkError(ctx context.Context, id uuid.UUID, err error) error
+	// saves the cursor and clears the error. only from active or resyncing, an
+	// item a webhook moved elsewhere mid-sync fails with ErrItemInactive or
+	// ErrItemStateChanged and keeps its status
+	UpdateSuccess(ctx context.Context, id uuid.UUID, cursor string, fencingToken int64) error
+	// records the fence without touching the cursor, fails like UpdateSuccess
+	// when a newer holder has written or the item left active or resyncing
+	AdvanceFence(ctx context.Context, id uuid.UUID, fencingToken int64) error
+	// only from active or resyncing, fenced like UpdateSuccess
+	MarkResyncing(ctx context.Context, id uuid.UUID, fencingToken int64) error
+	// moves the item to error, it stays there until someone acts on it. fenced
+	// and skipped for disconnected items like UpdateSuccess
+	MarkError(ctx context.Context, id uuid.UUID, itemErr domain.ItemError, fencingToken int64) error
+	// counts a failure the queue will retry without leaving the current status
+	RecordTransientError(ctx context.Context, id uuid.UUID, itemErr domain.ItemError, fencingToken int64) error
+	// flags only ever move from false to true
+	SetUpdateFlags(ctx context.Context, id uuid.UUID, initialComplete, historicalComplete bool) error
+	// lifecycle transitions, each applies only from the statuses it expects and
+	// fails with ErrItemInactive or ErrItemStateChanged otherwise
+	ReportError(ctx context.Context, id uuid.UUID, itemErr domain.ItemError) error
+	// back to active from error, clears the error
+	Reactivate(ctx context.Context, id uuid.UUID) error
+	SetConsentExpiration(ctx context.Context, id uuid.UUID, expiresAt time.Time) error
+	// drops the access token
+	Revoke(ctx context.Context, id uuid.UUID) error
+	// drops the access token and schedules the purge
+	Remove(ctx context.Context, id uuid.UUID, purgeAfter time.Time) error
+	// hard-deletes removed items due for purging, their transactions go with them
+	PurgeRemoved(ctx context.Context, now time.Time) (int64, error)
+	// newest first, only the filter's tenant is ever visible
+	List(ctx context.Context, filter ItemFilter) ([]*domain.Item, error)
+}
+
+// ItemFilter narrows ItemRepository.List, zero values match everything
+type ItemFilter struct {
+	TenantID  uuid.UUID
+	EndUserID string
+	Status    domain.SyncStatus
+
+	// continue after this item, nil for the first page
+	After *uuid.UUID
+	Limit int
 }
 
 type TransactionRepository interface {
 	UpsertBatch(ctx context.Context, txs []*domain.Transaction) error
 	MarkRemovedBatch(ctx context.Context, itemID uuid.UUID, plaidTXIDs []string) error
 	DeleteAllForItem(ctx context.Context, itemID uuid.UUID) error
+	GetByPlaidIDs(ctx context.Context, itemID uuid.UUID, plaidTXIDs []string) ([]*domain.Transaction, error)
+	ListActivePlaidIDs(ctx context.Context, itemID uuid.UUID) ([]string, error)
+	ListByDateRange(ctx context.Context, itemID uuid.UUID, start, end time.Time) ([]*domain.Transaction, error)
+	// flags the window's rows named in plaidTXIDs as phantoms and clears the
+	// flag on the window's other rows
+	SetPhantoms(ctx context.Context, itemID uuid.UUID, start, end time.Time, plaidTXIDs []string) error
+	// newest first by (date, id), only the filter's tenant is ever visible
+	List(ctx context.Context, filter TransactionFilter) ([]*domain.Transaction, error)
+	CountByItems(ctx context.Context, itemIDs []uuid.UUID) (map[uuid.UUID]TransactionCounts, error)
+}
+
+type TransactionCounts struct {
+	Pending int64
+	Posted  int64
+	Removed int64
+}
+
+// TransactionFilter narrows TransactionRepository.List, zero values match everything
+type TransactionFilter struct {
+	TenantID       uuid.UUID
+	ItemID         *uuid.UUID
+	AccountID      string
+	StartDate      *time.Time
+	EndDate        *time.Time
+	Status         domain.TransactionStatus
+	MinAmountCents *int64
+	MaxAmountCents *int64
+	// case-insensitive substring of the merchant name
+	Merchant       string
+	IncludeRemoved bool
+	// only phantoms when true, none when false
+	Phantom *bool
+
+	// continue after this position, nil for the first page
+	After *TransactionCursor
+	Limit int
+}
+
+type TransactionCursor struct {
+	Date time.Time
+	ID   uuid.UUID
+}
+
+type OutboxRepository interface {
+	// takes the relay's exclusive lock for the current transaction, false if
+	// another relay holds it
+	ClaimRelay(ctx context.Context) (bool, error)
+	// oldest due events, never one whose earlier event for the same item is still waiting
+	FetchPending(ctx context.Context, limit int) ([]*domain.OutboxEntry, error)
+	// moves events' next attempt, used to lease a batch while it is delivered
+	// outside the claiming transaction and to hand back what wasn't attempted
+	Reschedule(ctx context.Context, ids []int64, at time.Time) error
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, deliveryErr error, nextAttemptAt time.Time) error
+	// remembers which sinks took an event that failed elsewhere, so retries skip them
+	RecordDeliveredSinks(ctx context.Context, id int64, sinks []string) error
+	// gives up on an event, it stays in the table but no longer blocks its item
+	Park(ctx context.Context, id int64, deliveryErr error) error
+	CountParked(ctx context.Context) (int64, error)
+	DeleteSentBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type ReconciliationRepository interface {
+	Create(ctx context.Context, report *domain.ReconciliationReport) error
+}
+
+type WebhookSubscriptionRepository interface {
+	Create(ctx context.Context, sub *domain.WebhookSubscription) error
+	GetByID(ctx context.Context, tenantID, id uuid.UUID) (*domain.WebhookSubscription, error)
+	ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*domain.WebhookSubscription, error)
+	Delete(ctx context.Context, tenantID, id uuid.UUID) error
+}
+
+type WebhookDeliveryRepository interface {
+	Record(ctx context.Context, delivery *domain.WebhookDelivery) error
+	// attempts made so far for the event and whether any of them succeeded
+	Status(ctx context.Context, subscriptionID, eventID uuid.UUID) (attempts int, delivered bool, err error)
+	ListBySubscription(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*domain.WebhookDelivery, error)
+}
+
+type TenantRepository interface {
+	Create(ctx context.Context, tenant *domain.Tenant) error
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Tenant, error)
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	GetByHash(ctx context.Context, keyHash string) (*domain.APIKey, error)
+	// records use of the key, throttled so every request doesn't write
+	Touch(ctx context.Context, id uuid.UUID) error
+	Revoke(ctx context.Context, id uuid.UUID) error
 }
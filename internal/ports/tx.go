@@ -0,0 +1,9 @@
+package ports
+
+import "context"
+
+type TxManager interface {
+	// runs fn in a single database transaction. repositories called with the
+	// ctx handed to fn join that transaction, nested calls reuse it.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/alexchny/sync-relay/internal/domain"
 	"github.com/alexchny/sync-relay/internal/ports"
@@ -13,26 +14,53 @@ import (
 
 var ErrTokenAlreadyUsed = errors.New("public token already used")
 var ErrItemAlreadyLinked = errors.New("item already linked")
+var ErrItemRevoked = errors.New("item access was revoked, link it again")
+var ErrItemRemoved = errors.New("item was removed")
+var ErrItemOwnedElsewhere = errors.New("item is linked by another tenant")
 
 type AccountService struct {
-	plaidClient ports.PlaidClient
-	itemRepo    ports.ItemRepository
-	queue       ports.JobQueue
+	plaidClient    ports.PlaidClient
+	itemRepo       ports.ItemRepository
+	queue          ports.JobQueue
+	txManager      ports.TxManager
+	publisher      ports.EventPublisher
+	events         *EventBuilder
+	purgeRetention time.Duration
 }
 
-func NewAccountService(p ports.PlaidClient, r ports.ItemRepository, q ports.JobQueue) *AccountService {
+func NewAccountService(
+	p ports.PlaidClient,
+	r ports.ItemRepository,
+	q ports.JobQueue,
+	txManager ports.TxManager,
+	publisher ports.EventPublisher,
+	events *EventBuilder,
+	purgeRetention time.Duration,
+) *AccountService {
 	return &AccountService{
-		plaidClient: p,
-		itemRepo:    r,
-		queue:       q,
+		plaidClient:    p,
+		itemRepo:       r,
+		queue:          q,
+		txManager:      txManager,
+		publisher:      publisher,
+		events:         events,
+		purgeRetention: purgeRetention,
 	}
 }
 
-func (s *AccountService) CreateLinkToken(ctx context.Context, userID string) (string, error) {
-	return s.plaidClient.CreateLinkToken(ctx, userID)
+func (s *AccountService) CreateLinkToken(ctx context.Context, tenantID uuid.UUID, endUserID string) (string, error) {
+	return s.plaidClient.CreateLinkToken(ctx, plaidUserID(tenantID, endUserID))
 }
 
-func (s *AccountService) LinkItem(ctx context.Context, tenantID uuid.UUID, publicToken string) (uuid.UUID, error) {
+// plaid's client_user_id, tenants may reuse each other's end user ids
+func plaidUserID(tenantID uuid.UUID, endUserID string) string {
+	if endUserID == "" {
+		return tenantID.String()
+	}
+	return tenantID.String() + ":" + endUserID
+}
+
+func (s *AccountService) LinkItem(ctx context.Context, tenantID uuid.UUID, endUserID, publicToken string) (uuid.UUID, error) {
 	tokenResp, err := s.plaidClient.ExchangePublicToken(ctx, publicToken)
 	if err != nil {
 		if errors.Is(err, ports.ErrInvalidToken) {
@@ -46,6 +74,7 @@ func (s *AccountService) LinkItem(ctx context.Context, tenantID uuid.UUID, publi
 	item := &domain.Item{
 		ID:             itemID,
 		TenantID:       tenantID,
+		EndUserID:      endUserID,
 		PlaidItemID:    tokenResp.ItemID,
 		AccessTokenEnc: tokenResp.AccessToken,
 		SyncStatus:     "active",
@@ -61,6 +90,9 @@ func (s *AccountService) LinkItem(ctx context.Context, tenantID uuid.UUID, publi
 			if fetchErr != nil {
 				return uuid.Nil, fmt.Errorf("item exists but failed to fetch: %w", fetchErr)
 			}
+			if existingItem.TenantID != tenantID {
+				return uuid.Nil, ErrItemOwnedElsewhere
+			}
 			
 			return existingItem.ID, ErrItemAlreadyLinked
 		}
@@ -79,3 +111,148 @@ func (s *AccountService) LinkItem(ctx context.Context, tenantID uuid.UUID, publi
 	slog.Info("item linked successfully", "item_id", itemID, "plaid_item_id", tokenResp.ItemID)
 	return itemID, nil
 }
+
+// loadItem hides other tenants' items as if they didn't exist
+func (s *AccountService) loadItem(ctx context.Context, tenantID, itemID uuid.UUID) (*domain.Item, error) {
+	item, err := s.itemRepo.GetByID(ctx, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load item: %w", err)
+	}
+	if item.TenantID != tenantID {
+		return nil, fmt.Errorf("failed to load item: %w", ports.ErrItemNotFound)
+	}
+	return item, nil
+}
+
+func (s *AccountService) CreateUpdateLinkToken(ctx context.Context, tenantID, itemID uuid.UUID) (string, error) {
+	item, err := s.loadItem(ctx, tenantID, itemID)
+	if err != nil {
+		return "", err
+	}
+
+	if item.IsRemoved() {
+		return "", ErrItemRemoved
+	}
+	if item.IsRevoked() {
+		return "", ErrItemRevoked
+	}
+
+	return s.plaidClient.CreateUpdateLinkToken(ctx, plaidUserID(item.TenantID, item.EndUserID), item.AccessTokenEnc)
+}
+
+// CompleteItemRepair is called once the user finished link in update mode.
+// the item goes back to active and syncs whatever it missed.
+func (s *AccountService) CompleteItemRepair(ctx context.Context, tenantID, itemID uuid.UUID) error {
+	item, err := s.loadItem(ctx, tenantID, itemID)
+	if err != nil {
+		return err
+	}
+
+	if item.IsRemoved() {
+		return ErrItemRemoved
+	}
+	if item.IsRevoked() {
+		return ErrItemRevoked
+	}
+
+	if item.HasError() {
+		if err := s.itemRepo.Reactivate(ctx, item.ID); err != nil {
+			if errors.Is(err, ports.ErrItemInactive) {
+				// disconnected since it was loaded
+				return s.disconnectedErr(ctx, item.ID)
+			}
+			return err
+		}
+		item.MarkActive()
+	}
+
+	job := &domain.SyncJob{
+		ItemID:  item.ID,
+		JobType: domain.JobTypeStandard,
+		TraceID: uuid.NewString(),
+	}
+	if err := s.queue.Enqueue(ctx, job); err != nil {
+		return fmt.Errorf("failed to enqueue sync: %w", err)
+	}
+
+	slog.Info("item repaired", "item_id", item.ID)
+	return nil
+}
+
+func (s *AccountService) disconnectedErr(ctx context.Context, itemID uuid.UUID) error {
+	item, err := s.itemRepo.GetByID(ctx, itemID)
+	if err != nil {
+		return fmt.Errorf("failed to load item: %w", err)
+	}
+	if item.IsRevoked() {
+		return ErrItemRevoked
+	}
+	return ErrItemRemoved
+}
+
+// RemoveItem disconnects the item at plaid and stops syncing it. its rows
+// stay around for the purge retention so consumers can catch up first.
+func (s *AccountService) RemoveItem(ctx context.Context, tenantID, itemID uuid.UUID) (*domain.Item, error) {
+	item, err := s.loadItem(ctx, tenantID, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	// removing twice is a no-op
+	if item.IsRemoved() {
+		return item, nil
+	}
+
+	// a revoked item has no token, plaid already dropped it
+	if item.AccessTokenEnc != "" {
+		if err := s.plaidClient.RemoveItem(ctx, item.AccessTokenEnc); err != nil {
+			return nil, err
+		}
+	}
+
+	item.MarkRemoved(time.Now().Add(s.purgeRetention))
+
+	event, err := s.events.ItemLifecycle(item, uuid.NewString(), domain.EventTypeItemRemoved)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.itemRepo.Remove(ctx, item.ID, *item.PurgeAfter); err != nil {
+			return err
+		}
+		if err := s.publisher.Publish(ctx, event); err != nil {
+			return fmt.Errorf("failed to publish item event: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, ports.ErrItemInactive) {
+		// a concurrent removal got there first
+		return s.loadItem(ctx, tenantID, itemID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("item removed", "item_id", item.ID, "purge_after", item.PurgeAfter)
+	return item, nil
+}
+
+func (s *AccountService) RequestReconciliation(ctx context.Context, tenantID, itemID uuid.UUID) error {
+	item, err := s.loadItem(ctx, tenantID, itemID)
+	if err != nil {
+		return err
+	}
+
+	job := &domain.SyncJob{
+		ItemID:  item.ID,
+		JobType: domain.JobTypeReconciliation,
+		TraceID: uuid.NewString(),
+	}
+	if err := s.queue.Enqueue(ctx, job); err != nil {
+		return fmt.Errorf("failed to enqueue reconciliation: %w", err)
+	}
+
+	slog.Info("reconciliation queued", "item_id", item.ID)
+	return nil
+}
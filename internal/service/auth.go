@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+var ErrUnauthenticated = errors.New("missing or invalid credentials")
+
+const apiKeyPrefix = "srk_"
+
+// enough of the key to tell keys apart without being usable
+const apiKeyDisplayLen = len(apiKeyPrefix) + 8
+
+type AuthService struct {
+	tenants ports.TenantRepository
+	keys    ports.APIKeyRepository
+	// nil when end-user session tokens aren't configured
+	sessions ports.SessionTokenVerifier
+}
+
+func NewAuthService(t ports.TenantRepository, k ports.APIKeyRepository, sessions ports.SessionTokenVerifier) *AuthService {
+	return &AuthService{
+		tenants:  t,
+		keys:     k,
+		sessions: sessions,
+	}
+}
+
+// IsAPIKey tells api keys apart from session tokens sent the same way
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, apiKeyPrefix)
+}
+
+func (s *AuthService) CreateTenant(ctx context.Context, name string) (*domain.Tenant, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("tenant name is required")
+	}
+
+	tenant := &domain.Tenant{
+		ID:   uuid.New(),
+		Name: name,
+	}
+	if err := s.tenants.Create(ctx, tenant); err != nil {
+		return nil, err
+	}
+
+	slog.Info("tenant created", "tenant_id", tenant.ID)
+	return tenant, nil
+}
+
+// CreateAPIKey returns the raw key, it can't be recovered later
+func (s *AuthService) CreateAPIKey(ctx context.Context, tenantID uuid.UUID, name string) (string, *domain.APIKey, error) {
+	if _, err := s.tenants.GetByID(ctx, tenantID); err != nil {
+		return "", nil, err
+	}
+
+	raw, err := newAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := &domain.APIKey{
+		ID:       uuid.New(),
+		TenantID: tenantID,
+		Name:     name,
+		Prefix:   raw[:apiKeyDisplayLen],
+		KeyHash:  hashAPIKey(raw),
+	}
+	if err := s.keys.Create(ctx, key); err != nil {
+		return "", nil, err
+	}
+
+	slog.Info("api key created", "key_id", key.ID, "tenant_id", tenantID)
+	return raw, key, nil
+}
+
+func (s *AuthService) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	if err := s.keys.Revoke(ctx, id); err != nil {
+		return err
+	}
+
+	slog.Info("api key revoked", "key_id", id)
+	return nil
+}
+
+func (s *AuthService) AuthenticateAPIKey(ctx context.Context, raw string) (Principal, error) {
+	if !IsAPIKey(raw) {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	key, err := s.keys.GetByHash(ctx, hashAPIKey(raw))
+	if err != nil {
+		if errors.Is(err, ports.ErrAPIKeyNotFound) {
+			return Principal{}, ErrUnauthenticated
+		}
+		return Principal{}, err
+	}
+	if key.IsRevoked() {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	// last-used is informational, don't fail the request over it
+	if err := s.keys.Touch(ctx, key.ID); err != nil {
+		slog.Warn("failed to record api key use", "key_id", key.ID, "error", err)
+	}
+
+	return Principal{
+		TenantID: key.TenantID,
+		Subject:  "api_key:" + key.ID.String(),
+	}, nil
+}
+
+func (s *AuthService) AuthenticateSessionToken(ctx context.Context, token string) (Principal, error) {
+	if s.sessions == nil {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	identity, err := s.sessions.VerifySessionToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, ports.ErrInvalidSessionToken) {
+			slog.Debug("rejected session token", "error", err)
+			return Principal{}, ErrUnauthenticated
+		}
+		return Principal{}, err
+	}
+
+	// a validly signed token can still name a tenant we don't have
+	if _, err := s.tenants.GetByID(ctx, identity.TenantID); err != nil {
+		if errors.Is(err, ports.ErrTenantNotFound) {
+			slog.Warn("session token for unknown tenant", "tenant_id", identity.TenantID)
+			return Principal{}, ErrUnauthenticated
+		}
+		return Principal{}, err
+	}
+
+	return Principal{
+		TenantID:  identity.TenantID,
+		EndUserID: identity.EndUserID,
+		Subject:   "end_user:" + identity.EndUserID,
+	}, nil
+}
+
+func newAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(b), nil
+}
+
+// keys are high-entropy random strings, a plain sha256 is enough
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
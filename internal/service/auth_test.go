@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+type memTenants struct {
+	tenants map[uuid.UUID]*domain.Tenant
+}
+
+func (m *memTenants) Create(ctx context.Context, tenant *domain.Tenant) error {
+	m.tenants[tenant.ID] = tenant
+	return nil
+}
+
+func (m *memTenants) GetByID(ctx context.Context, id uuid.UUID) (*domain.Tenant, error) {
+	tenant, ok := m.tenants[id]
+	if !ok {
+		return nil, ports.ErrTenantNotFound
+	}
+	return tenant, nil
+}
+
+type memAPIKeys struct {
+	keys    map[uuid.UUID]*domain.APIKey
+	touched int
+	err     error
+}
+
+func (m *memAPIKeys) Create(ctx context.Context, key *domain.APIKey) error {
+	m.keys[key.ID] = key
+	return nil
+}
+
+func (m *memAPIKeys) GetByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	for _, key := range m.keys {
+		if key.KeyHash == keyHash {
+			return key, nil
+		}
+	}
+	return nil, ports.ErrAPIKeyNotFound
+}
+
+func (m *memAPIKeys) Touch(ctx context.Context, id uuid.UUID) error {
+	m.touched++
+	return nil
+}
+
+func (m *memAPIKeys) Revoke(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	m.keys[id].RevokedAt = &now
+	return nil
+}
+
+func newTestAuth(sessions ports.SessionTokenVerifier) (*AuthService, *memAPIKeys) {
+	keys := &memAPIKeys{keys: make(map[uuid.UUID]*domain.APIKey)}
+	tenants := &memTenants{tenants: make(map[uuid.UUID]*domain.Tenant)}
+	return NewAuthService(tenants, keys, sessions), keys
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	ctx := context.Background()
+	auth, keys := newTestAuth(nil)
+
+	tenant, err := auth.CreateTenant(ctx, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, key, err := auth.CreateAPIKey(ctx, tenant.ID, "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the hash is stored
+	if key.KeyHash == raw || key.KeyHash != hashAPIKey(raw) || !IsAPIKey(raw) || raw[:len(key.Prefix)] != key.Prefix {
+		t.Fatalf("stored key %+v doesn't match raw key", key)
+	}
+
+	principal, err := auth.AuthenticateAPIKey(ctx, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.TenantID != tenant.ID || principal.EndUserID != "" || principal.Subject != "api_key:"+key.ID.String() {
+		t.Fatalf("principal = %+v, want the key's tenant", principal)
+	}
+	if keys.touched != 1 {
+		t.Fatalf("touched = %d, want 1", keys.touched)
+	}
+
+	for _, bad := range []string{"", "srk_unknown", raw[len(apiKeyPrefix):], raw + "x"} {
+		if _, err := auth.AuthenticateAPIKey(ctx, bad); !errors.Is(err, ErrUnauthenticated) {
+			t.Fatalf("AuthenticateAPIKey(%q) = %v, want ErrUnauthenticated", bad, err)
+		}
+	}
+
+	if err := auth.RevokeAPIKey(ctx, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.AuthenticateAPIKey(ctx, raw); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("revoked key: error = %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestAuthenticateAPIKeyStoreFailure(t *testing.T) {
+	auth, keys := newTestAuth(nil)
+	keys.err = errors.New("connection refused")
+
+	// an outage isn't a bad credential
+	_, err := auth.AuthenticateAPIKey(context.Background(), "srk_abc")
+	if err == nil || errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("error = %v, want the store error", err)
+	}
+}
+
+func TestCreateAPIKeyUnknownTenant(t *testing.T) {
+	auth, keys := newTestAuth(nil)
+
+	if _, _, err := auth.CreateAPIKey(context.Background(), uuid.New(), "ci"); !errors.Is(err, ports.ErrTenantNotFound) {
+		t.Fatalf("error = %v, want ErrTenantNotFound", err)
+	}
+	if len(keys.keys) != 0 {
+		t.Fatal("key created for an unknown tenant")
+	}
+}
+
+type stubVerifier struct {
+	identity *ports.EndUserIdentity
+	err      error
+}
+
+func (v stubVerifier) VerifySessionToken(ctx context.Context, token string) (*ports.EndUserIdentity, error) {
+	return v.identity, v.err
+}
+
+func TestAuthenticateSessionToken(t *testing.T) {
+	ctx := context.Background()
+	tenantID := uuid.New()
+
+	tests := []struct {
+		name     string
+		sessions ports.SessionTokenVerifier
+		wantErr  error
+	}{
+		{"valid", stubVerifier{identity: &ports.EndUserIdentity{TenantID: tenantID, EndUserID: "user-42"}}, nil},
+		{"not configured", nil, ErrUnauthenticated},
+		{"rejected token", stubVerifier{err: fmt.Errorf("%w: token is expired", ports.ErrInvalidSessionToken)}, ErrUnauthenticated},
+		{"unknown tenant", stubVerifier{identity: &ports.EndUserIdentity{TenantID: uuid.New(), EndUserID: "user-42"}}, ErrUnauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, _ := newTestAuth(tt.sessions)
+			auth.tenants.(*memTenants).tenants[tenantID] = &domain.Tenant{ID: tenantID}
+
+			principal, err := auth.AuthenticateSessionToken(ctx, "token")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if principal.TenantID != tenantID || principal.EndUserID != "user-42" || principal.Subject != "end_user:user-42" {
+				t.Fatalf("principal = %+v, want the token's end user", principal)
+			}
+		})
+	}
+}
+
+func TestAuthenticateSessionTokenVerifierFailure(t *testing.T) {
+	auth, _ := newTestAuth(stubVerifier{err: errors.New("jwks unavailable")})
+
+	// an idp outage isn't a bad credential
+	_, err := auth.AuthenticateSessionToken(context.Background(), "token")
+	if err == nil || errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("error = %v, want the verifier error", err)
+	}
+}
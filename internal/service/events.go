@@ -0,0 +1,153 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+)
+
+type EventBuilder struct {
+	maxPayloadBytes int
+}
+
+func NewEventBuilder(maxPayloadBytes int) *EventBuilder {
+	return &EventBuilder{maxPayloadBytes: maxPayloadBytes}
+}
+
+// TransactionChanges builds one event per kind of change in a page. previous
+// holds the stored rows keyed by plaid transaction id so modifications and
+// removals can carry the values they replaced. events that don't fit the
+// size limit fall back to reference-only changes.
+func (b *EventBuilder) TransactionChanges(
+	item *domain.Item,
+	traceID string,
+	added, modified []*domain.Transaction,
+	removed []string,
+	previous map[string]*domain.Transaction,
+) ([]*domain.Event, error) {
+	var events []*domain.Event
+
+	if len(added) > 0 {
+		changes := make([]domain.TransactionChange, 0, len(added))
+		for _, tx := range added {
+			changes = append(changes, domain.TransactionChange{
+				Kind:          domain.ChangeKindAdded,
+				TransactionID: tx.PlaidTransactionID,
+				Current:       domain.NewTransactionSnapshot(tx),
+			})
+		}
+
+		event, err := b.build(item, traceID, domain.EventTypeTransactionsAdded, changes)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if len(modified) > 0 {
+		changes := make([]domain.TransactionChange, 0, len(modified))
+		for _, tx := range modified {
+			change := domain.TransactionChange{
+				Kind:          domain.ChangeKindModified,
+				TransactionID: tx.PlaidTransactionID,
+				Current:       domain.NewTransactionSnapshot(tx),
+			}
+			if prev, ok := previous[tx.PlaidTransactionID]; ok {
+				change.Previous = domain.NewTransactionSnapshot(prev)
+			}
+			changes = append(changes, change)
+		}
+
+		event, err := b.build(item, traceID, domain.EventTypeTransactionsModified, changes)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if len(removed) > 0 {
+		changes := make([]domain.TransactionChange, 0, len(removed))
+		for _, id := range removed {
+			change := domain.TransactionChange{
+				Kind:          domain.ChangeKindRemoved,
+				TransactionID: id,
+			}
+			if prev, ok := previous[id]; ok {
+				change.Previous = domain.NewTransactionSnapshot(prev)
+			}
+			changes = append(changes, change)
+		}
+
+		event, err := b.build(item, traceID, domain.EventTypeTransactionsRemoved, changes)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (b *EventBuilder) ItemLifecycle(item *domain.Item, traceID string, eventType domain.EventType) (*domain.Event, error) {
+	payload := domain.ItemLifecyclePayload{
+		SchemaVersion:    domain.EventSchemaVersion,
+		ItemID:           item.ID.String(),
+		Status:           item.SyncStatus,
+		ErrorCode:        item.ErrorCode,
+		ErrorMessage:     item.ErrorMessage,
+		ConsentExpiresAt: item.ConsentExpiresAt,
+		PurgeAfter:       item.PurgeAfter,
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	return domain.NewEvent(item.TenantID, item.ID, eventType, data, traceID), nil
+}
+
+func (b *EventBuilder) build(item *domain.Item, traceID string, eventType domain.EventType, changes []domain.TransactionChange) (*domain.Event, error) {
+	payload := domain.TransactionChangesPayload{
+		SchemaVersion: domain.EventSchemaVersion,
+		ItemID:        item.ID.String(),
+		Mode:          domain.PayloadModeFull,
+		Count:         len(changes),
+		Changes:       changes,
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	if b.maxPayloadBytes > 0 && len(data) > b.maxPayloadBytes {
+		data, err = referenceOnly(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return domain.NewEvent(item.TenantID, item.ID, eventType, data, traceID), nil
+}
+
+func referenceOnly(payload domain.TransactionChangesPayload) ([]byte, error) {
+	payload.Mode = domain.PayloadModeReference
+
+	changes := make([]domain.TransactionChange, 0, len(payload.Changes))
+	for _, change := range payload.Changes {
+		changes = append(changes, domain.TransactionChange{
+			Kind:          change.Kind,
+			TransactionID: change.TransactionID,
+		})
+	}
+	payload.Changes = changes
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode reference-only event: %w", err)
+	}
+
+	return data, nil
+}
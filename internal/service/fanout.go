@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+// per-sink counters, served on /debug/vars
+var sinkMetrics = expvar.NewMap("event_sinks")
+
+var ErrSinkBufferFull = errors.New("sink buffer is full")
+
+type FailurePolicy string
+
+const (
+	// a failed delivery fails the publish, the outbox retries the event
+	FailurePolicyBlock FailurePolicy = "block"
+	// events are buffered in memory and retried in the background, a failure
+	// never holds back other sinks
+	FailurePolicyAsync FailurePolicy = "async"
+)
+
+// SinkFilter decides which events a sink receives. empty fields match everything.
+type SinkFilter struct {
+	TenantIDs  []uuid.UUID
+	EventTypes []domain.EventType
+	// transaction events pass if any change moves at least this much, in
+	// either direction. item events are never held back by it.
+	MinAmountCents int64
+}
+
+func (f SinkFilter) Allows(e *domain.Event) bool {
+	if len(f.TenantIDs) > 0 && !containsTenant(f.TenantIDs, e.TenantID) {
+		return false
+	}
+	if len(f.EventTypes) > 0 && !containsType(f.EventTypes, e.Type) {
+		return false
+	}
+	if f.MinAmountCents > 0 && e.Type.Category() == "transactions" {
+		return meetsAmount(e.Payload, f.MinAmountCents)
+	}
+	return true
+}
+
+func containsTenant(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, t := range ids {
+		if t == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsType(types []domain.EventType, t domain.EventType) bool {
+	for _, et := range types {
+		if et == t {
+			return true
+		}
+	}
+	return false
+}
+
+func meetsAmount(payload []byte, minCents int64) bool {
+	var p domain.TransactionChangesPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return true
+	}
+
+	// reference-only events carry no amounts, let the consumer decide
+	if p.Mode == domain.PayloadModeReference {
+		return true
+	}
+
+	for _, c := range p.Changes {
+		for _, snap := range []*domain.TransactionSnapshot{c.Current, c.Previous} {
+			if snap != nil && abs(snap.AmountCents) >= minCents {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+type Sink struct {
+	Name       string
+	Publisher  ports.EventPublisher
+	Filter     SinkFilter
+	Policy     FailurePolicy
+	BufferSize int
+}
+
+type sinkRunner struct {
+	Sink
+	buffer  chan *domain.Event
+	metrics *expvar.Map
+}
+
+// FanOutPublisher delivers every event to each sink whose filter allows it.
+// a blocking sink that fails makes the whole publish fail. the outbox relay
+// records which sinks took the event through PublishExcept, so the retry
+// only goes to the sinks that failed.
+type FanOutPublisher struct {
+	sinks []*sinkRunner
+	retry RetryPolicy
+}
+
+func NewFanOutPublisher(sinks []Sink, retry RetryPolicy) *FanOutPublisher {
+	runners := make([]*sinkRunner, 0, len(sinks))
+	for _, s := range sinks {
+		metrics := new(expvar.Map).Init()
+		sinkMetrics.Set(s.Name, metrics)
+
+		runner := &sinkRunner{Sink: s, metrics: metrics}
+		if s.Policy == FailurePolicyAsync {
+			runner.buffer = make(chan *domain.Event, s.BufferSize)
+		}
+		runners = append(runners, runner)
+	}
+
+	return &FanOutPublisher{sinks: runners, retry: retry}
+}
+
+// Run delivers buffered events for async sinks until ctx is cancelled. events
+// still buffered at shutdown are lost, they were already marked sent.
+func (p *FanOutPublisher) Run(ctx context.Context) {
+	done := make(chan struct{})
+	running := 0
+
+	for _, s := range p.sinks {
+		if s.buffer == nil {
+			continue
+		}
+		running++
+		go func(s *sinkRunner) {
+			defer func() { done <- struct{}{} }()
+			p.drain(ctx, s)
+		}(s)
+	}
+
+	for ; running > 0; running-- {
+		<-done
+	}
+}
+
+func (p *FanOutPublisher) Publish(ctx context.Context, events ...*domain.Event) error {
+	var failed []error
+	for _, e := range events {
+		if _, err := p.PublishExcept(ctx, e, nil); err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// PublishExcept delivers e to every sink not named in delivered and returns
+// the names of all sinks that have it now, including on failure. an async
+// sink has it once it is buffered.
+func (p *FanOutPublisher) PublishExcept(ctx context.Context, e *domain.Event, delivered []string) ([]string, error) {
+	var failed []error
+	done := slices.Clone(delivered)
+
+	for _, s := range p.sinks {
+		if slices.Contains(delivered, s.Name) {
+			s.metrics.Add("skipped", 1)
+			continue
+		}
+		if !s.Filter.Allows(e) {
+			s.metrics.Add("filtered", 1)
+			continue
+		}
+
+		if s.buffer != nil {
+			if err := s.enqueue(e); err != nil {
+				failed = append(failed, err)
+				continue
+			}
+			done = append(done, s.Name)
+			continue
+		}
+
+		if err := s.Publisher.Publish(ctx, e); err != nil {
+			s.metrics.Add("failed", 1)
+			failed = append(failed, fmt.Errorf("sink %s: %w", s.Name, err))
+			continue
+		}
+		s.metrics.Add("delivered", 1)
+		done = append(done, s.Name)
+	}
+
+	return done, errors.Join(failed...)
+}
+
+func (s *sinkRunner) enqueue(e *domain.Event) error {
+	select {
+	case s.buffer <- e:
+		s.metrics.Add("queued", 1)
+		return nil
+	default:
+		// push back on the outbox instead of dropping
+		s.metrics.Add("failed", 1)
+		return fmt.Errorf("sink %s: %w", s.Name, ErrSinkBufferFull)
+	}
+}
+
+func (p *FanOutPublisher) drain(ctx context.Context, s *sinkRunner) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-s.buffer:
+			s.metrics.Add("queued", -1)
+			p.deliverWithRetry(ctx, s, e)
+		}
+	}
+}
+
+func (p *FanOutPublisher) deliverWithRetry(ctx context.Context, s *sinkRunner, e *domain.Event) {
+	for attempt := 1; ; attempt++ {
+		err := s.Publisher.Publish(ctx, e)
+		if err == nil {
+			s.metrics.Add("delivered", 1)
+			return
+		}
+		s.metrics.Add("failed", 1)
+
+		if attempt >= p.retry.MaxAttempts {
+			s.metrics.Add("dropped", 1)
+			slog.Error("async sink gave up on event",
+				"sink", s.Name,
+				"event_id", e.ID,
+				"attempts", attempt,
+				"error", err,
+			)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.retry.Backoff(attempt)):
+		}
+	}
+}
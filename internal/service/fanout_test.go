@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/google/uuid"
+)
+
+// flakyPublisher fails its first failures calls, then records what it gets
+type flakyPublisher struct {
+	mu        sync.Mutex
+	failures  int
+	calls     int
+	delivered []*domain.Event
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, events ...*domain.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls++
+	if p.calls <= p.failures {
+		return errors.New("sink unavailable")
+	}
+	p.delivered = append(p.delivered, events...)
+	return nil
+}
+
+func (p *flakyPublisher) snapshot() (calls int, delivered []*domain.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls, append([]*domain.Event(nil), p.delivered...)
+}
+
+func transactionEvent(t *testing.T, tenantID uuid.UUID, amounts ...int64) *domain.Event {
+	t.Helper()
+
+	payload := domain.TransactionChangesPayload{Mode: domain.PayloadModeFull}
+	for _, amount := range amounts {
+		payload.Changes = append(payload.Changes, domain.TransactionChange{
+			Kind:    domain.ChangeKindAdded,
+			Current: &domain.TransactionSnapshot{AmountCents: amount},
+		})
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return domain.NewEvent(tenantID, uuid.New(), domain.EventTypeTransactionsAdded, data, "trace")
+}
+
+func TestSinkFilterAllows(t *testing.T) {
+	tenant := uuid.New()
+	other := uuid.New()
+
+	tests := []struct {
+		name   string
+		filter SinkFilter
+		event  *domain.Event
+		want   bool
+	}{
+		{"empty filter", SinkFilter{}, transactionEvent(t, tenant, 100), true},
+		{"tenant listed", SinkFilter{TenantIDs: []uuid.UUID{tenant}}, transactionEvent(t, tenant, 100), true},
+		{"tenant not listed", SinkFilter{TenantIDs: []uuid.UUID{other}}, transactionEvent(t, tenant, 100), false},
+		{"type not listed", SinkFilter{EventTypes: []domain.EventType{domain.EventTypeItemError}}, transactionEvent(t, tenant, 100), false},
+		{"refund meets min amount", SinkFilter{MinAmountCents: 500}, transactionEvent(t, tenant, 10, -700), true},
+		{"below min amount", SinkFilter{MinAmountCents: 500}, transactionEvent(t, tenant, 10, -20), false},
+		{
+			"item events ignore min amount",
+			SinkFilter{MinAmountCents: 500},
+			domain.NewEvent(tenant, uuid.New(), domain.EventTypeItemError, []byte(`{}`), "trace"),
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Allows(tt.event); got != tt.want {
+				t.Errorf("Allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFanOutBlockingSinkFailureFailsPublish(t *testing.T) {
+	failing := &flakyPublisher{failures: 1}
+	healthy := &flakyPublisher{}
+
+	fanOut := NewFanOutPublisher([]Sink{
+		{Name: "test-block-failing", Publisher: failing, Policy: FailurePolicyBlock},
+		{Name: "test-block-healthy", Publisher: healthy, Policy: FailurePolicyBlock},
+	}, RetryPolicy{MaxAttempts: 1})
+
+	event := transactionEvent(t, uuid.New(), 100)
+
+	// the outbox keeps the event and retries it
+	done, err := fanOut.PublishExcept(context.Background(), event, nil)
+	if err == nil {
+		t.Fatal("publish succeeded with a failing blocking sink")
+	}
+
+	// the other sink still got it and is reported as done
+	if _, delivered := healthy.snapshot(); len(delivered) != 1 {
+		t.Fatalf("healthy sink got %d events, want 1", len(delivered))
+	}
+	if len(done) != 1 || done[0] != "test-block-healthy" {
+		t.Fatalf("done = %v, want [test-block-healthy]", done)
+	}
+
+	// the retry only goes to the sink that failed
+	done, err = fanOut.PublishExcept(context.Background(), event, done)
+	if err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if _, delivered := failing.snapshot(); len(delivered) != 1 || delivered[0].ID != event.ID {
+		t.Fatalf("failing sink got %v on retry, want the event", delivered)
+	}
+	if calls, _ := healthy.snapshot(); calls != 1 {
+		t.Fatalf("healthy sink called %d times, want 1", calls)
+	}
+	if len(done) != 2 {
+		t.Fatalf("done = %v, want both sinks", done)
+	}
+}
+
+func TestFanOutSkipsFilteredSinks(t *testing.T) {
+	failing := &flakyPublisher{failures: 1}
+
+	fanOut := NewFanOutPublisher([]Sink{
+		{Name: "test-filtered", Publisher: failing, Policy: FailurePolicyBlock, Filter: SinkFilter{TenantIDs: []uuid.UUID{uuid.New()}}},
+	}, RetryPolicy{MaxAttempts: 1})
+
+	// a sink that doesn't want the event can't fail it
+	if err := fanOut.Publish(context.Background(), transactionEvent(t, uuid.New(), 100)); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	if calls, _ := failing.snapshot(); calls != 0 {
+		t.Fatalf("filtered sink called %d times", calls)
+	}
+}
+
+func TestFanOutAsyncSinkRetriesInBackground(t *testing.T) {
+	flaky := &flakyPublisher{failures: 2}
+
+	fanOut := NewFanOutPublisher([]Sink{
+		{Name: "test-async-retry", Publisher: flaky, Policy: FailurePolicyAsync, BufferSize: 4},
+	}, RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+
+	event := transactionEvent(t, uuid.New(), 100)
+
+	// failures never reach the outbox
+	if err := fanOut.Publish(context.Background(), event); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fanOut.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		calls, delivered := flaky.snapshot()
+		if len(delivered) == 1 {
+			if calls != 3 {
+				t.Fatalf("delivered after %d calls, want 3", calls)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("event not delivered, %d calls", calls)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFanOutAsyncSinkGivesUpAfterMaxAttempts(t *testing.T) {
+	broken := &flakyPublisher{failures: 100}
+
+	fanOut := NewFanOutPublisher([]Sink{
+		{Name: "test-async-drop", Publisher: broken, Policy: FailurePolicyAsync, BufferSize: 1},
+	}, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+
+	runner := fanOut.sinks[0]
+	fanOut.deliverWithRetry(context.Background(), runner, transactionEvent(t, uuid.New(), 100))
+
+	if calls, _ := broken.snapshot(); calls != 3 {
+		t.Fatalf("calls = %d, want 3", calls)
+	}
+	if dropped := runner.metrics.Get("dropped"); dropped == nil || dropped.String() != "1" {
+		t.Fatalf("dropped = %v, want 1", dropped)
+	}
+}
+
+func TestFanOutAsyncBufferFullPushesBack(t *testing.T) {
+	fanOut := NewFanOutPublisher([]Sink{
+		{Name: "test-async-full", Publisher: &flakyPublisher{}, Policy: FailurePolicyAsync, BufferSize: 1},
+	}, RetryPolicy{MaxAttempts: 1})
+
+	// nothing drains the buffer, the second event doesn't fit
+	err := fanOut.Publish(context.Background(), transactionEvent(t, uuid.New(), 100), transactionEvent(t, uuid.New(), 200))
+	if !errors.Is(err, ErrSinkBufferFull) {
+		t.Fatalf("error = %v, want ErrSinkBufferFull", err)
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+// ItemOverview is an item with what support needs to explain stale data
+type ItemOverview struct {
+	Item         *domain.Item
+	Transactions ports.TransactionCounts
+	// a job for the item is queued, backing off or being worked on
+	Queued bool
+	// a worker holds the item's lock and is syncing or reconciling it
+	Running bool
+}
+
+type ItemPage struct {
+	Items []*ItemOverview
+	// empty on the last page
+	NextCursor string
+}
+
+type ItemService struct {
+	itemRepo ports.ItemRepository
+	txRepo   ports.TransactionRepository
+	queue    ports.JobQueueInspector
+	locks    ports.LockInspector
+}
+
+func NewItemService(
+	itemRepo ports.ItemRepository,
+	txRepo ports.TransactionRepository,
+	queue ports.JobQueueInspector,
+	locks ports.LockInspector,
+) *ItemService {
+	return &ItemService{
+		itemRepo: itemRepo,
+		txRepo:   txRepo,
+		queue:    queue,
+		locks:    locks,
+	}
+}
+
+func (s *ItemService) Get(ctx context.Context, tenantID, itemID uuid.UUID) (*ItemOverview, error) {
+	item, err := s.itemRepo.GetByID(ctx, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load item: %w", err)
+	}
+	if item.TenantID != tenantID {
+		return nil, fmt.Errorf("failed to load item: %w", ports.ErrItemNotFound)
+	}
+
+	overviews, err := s.overviews(ctx, []*domain.Item{item})
+	if err != nil {
+		return nil, err
+	}
+
+	return overviews[0], nil
+}
+
+// List returns one page of the tenant's items. cursor is the NextCursor of
+// the previous page, empty for the first.
+func (s *ItemService) List(ctx context.Context, tenantID uuid.UUID, filter ports.ItemFilter, cursor string) (*ItemPage, error) {
+	if filter.Limit < 1 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+
+	if cursor != "" {
+		after, err := uuid.Parse(cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		filter.After = &after
+	}
+
+	limit := filter.Limit
+	filter.TenantID = tenantID
+	// one extra row tells us whether there is another page
+	filter.Limit = limit + 1
+
+	items, err := s.itemRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ItemPage{}
+	if len(items) > limit {
+		items = items[:limit]
+		page.NextCursor = items[limit-1].ID.String()
+	}
+
+	page.Items, err = s.overviews(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (s *ItemService) overviews(ctx context.Context, items []*domain.Item) ([]*ItemOverview, error) {
+	overviews := make([]*ItemOverview, 0, len(items))
+	if len(items) == 0 {
+		return overviews, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(items))
+	lockKeys := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+		lockKeys = append(lockKeys, syncLockKey(item.ID))
+	}
+
+	counts, err := s.txRepo.CountByItems(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	queued, err := s.queue.QueuedItems(ctx, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect queue: %w", err)
+	}
+
+	held, err := s.locks.Held(ctx, lockKeys...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect locks: %w", err)
+	}
+
+	for _, item := range items {
+		_, isQueued := queued[item.ID]
+		overviews = append(overviews, &ItemOverview{
+			Item:         item,
+			Transactions: counts[item.ID],
+			Queued:       isQueued,
+			Running:      held[syncLockKey(item.ID)],
+		})
+	}
+
+	return overviews, nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+// syncs and reconciliations of an item share one lock
+func syncLockKey(itemID uuid.UUID) string {
+	return fmt.Sprintf("sync:lock:%s", itemID)
+}
+
+// heldLock keeps a distributed lock alive while work runs under it. the
+// context it carries is cancelled as soon as a renewal fails, so nothing
+// keeps writing once another worker could have taken over.
+type heldLock struct {
+	ports.Lock
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+func acquireLock(ctx context.Context, lock ports.DistributedLock, key string, ttl time.Duration) (*heldLock, error) {
+	l, err := lock.Acquire(ctx, key, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	held := &heldLock{
+		Lock:   l,
+		ctx:    lockCtx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go held.renew(key, ttl)
+
+	return held, nil
+}
+
+func (h *heldLock) renew(key string, ttl time.Duration) {
+	defer close(h.done)
+
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.Refresh(h.ctx); err != nil {
+				if h.ctx.Err() != nil {
+					return
+				}
+				slog.Error("lock renewal failed, cancelling work", "key", key, "error", err)
+				h.cancel(fmt.Errorf("%w: %w", ports.ErrLockLost, err))
+				return
+			}
+		}
+	}
+}
+
+// err returns the reason work under the lock was cut short, if it was.
+func (h *heldLock) err() error {
+	if cause := context.Cause(h.ctx); errors.Is(cause, ports.ErrLockLost) {
+		return cause
+	}
+	return nil
+}
+
+func (h *heldLock) release() {
+	h.cancel(nil)
+	<-h.done
+
+	if err := h.Release(); err != nil {
+		slog.Warn("failed to release lock", "error", err)
+	}
+}
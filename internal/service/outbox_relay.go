@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+// sinkSet is a sink made of named sinks that reports which of them took an
+// event, so a retry only goes to the ones that failed
+type sinkSet interface {
+	PublishExcept(ctx context.Context, e *domain.Event, delivered []string) ([]string, error)
+}
+
+// parked: events given up on since start, parked_total: all parked rows as of the last check
+var outboxMetrics = expvar.NewMap("outbox")
+
+type OutboxRelay struct {
+	outbox       ports.OutboxRepository
+	txManager    ports.TxManager
+	sink         ports.EventPublisher
+	batchSize    int
+	pollInterval time.Duration
+	retention    time.Duration
+	lease        time.Duration
+	// attempts are capped by backoff.MaxAttempts, then the event is parked
+	backoff RetryPolicy
+}
+
+func NewOutboxRelay(
+	outbox ports.OutboxRepository,
+	txManager ports.TxManager,
+	sink ports.EventPublisher,
+	batchSize int,
+	pollInterval time.Duration,
+	retention time.Duration,
+	maxRetryDelay time.Duration,
+	lease time.Duration,
+	maxAttempts int,
+) *OutboxRelay {
+	return &OutboxRelay{
+		outbox:       outbox,
+		txManager:    txManager,
+		sink:         sink,
+		batchSize:    batchSize,
+		pollInterval: pollInterval,
+		retention:    retention,
+		lease:        lease,
+		backoff:      RetryPolicy{MaxAttempts: maxAttempts, BaseDelay: time.Second, MaxDelay: maxRetryDelay},
+	}
+}
+
+func (r *OutboxRelay) Run(ctx context.Context) {
+	poll := time.NewTicker(r.pollInterval)
+	defer poll.Stop()
+
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	r.reportParked(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+			// drain while there is a full backlog instead of waiting a tick per batch
+			for {
+				delivered, err := r.RelayBatch(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						slog.Error("outbox relay failed", "error", err)
+					}
+					break
+				}
+				if delivered < r.batchSize {
+					break
+				}
+			}
+		case <-cleanup.C:
+			deleted, err := r.outbox.DeleteSentBefore(ctx, time.Now().Add(-r.retention))
+			if err != nil {
+				slog.Error("failed to clean up outbox", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				slog.Info("cleaned up delivered outbox events", "count", deleted)
+			}
+			r.reportParked(ctx)
+		}
+	}
+}
+
+// RelayBatch delivers one batch of due events in order and returns how many
+// were handled. a failed event is rescheduled with backoff and holds back the
+// rest of its item's events so per-item order is kept. once it has used up
+// its attempts it is parked and stops holding them back.
+//
+// the batch is claimed and leased in a short transaction and delivered outside
+// it, so slow sinks don't hold a connection or the relay lock. delivery stops
+// when the lease runs out and whatever wasn't attempted is handed back.
+func (r *OutboxRelay) RelayBatch(ctx context.Context) (int, error) {
+	var entries []*domain.OutboxEntry
+
+	err := r.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		claimed, err := r.outbox.ClaimRelay(ctx)
+		if err != nil || !claimed {
+			return err
+		}
+
+		entries, err = r.outbox.FetchPending(ctx, r.batchSize)
+		if err != nil {
+			return err
+		}
+
+		return r.outbox.Reschedule(ctx, sequences(entries), time.Now().Add(r.lease))
+	})
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+
+	deliverCtx, cancel := context.WithTimeout(ctx, r.lease)
+	defer cancel()
+
+	handled := 0
+	blocked := make(map[uuid.UUID]bool)
+	var skipped []*domain.OutboxEntry
+
+	for _, entry := range entries {
+		event := entry.Event
+		if blocked[event.ItemID] || deliverCtx.Err() != nil {
+			skipped = append(skipped, entry)
+			continue
+		}
+		handled++
+
+		delivered, deliverErr := r.deliver(deliverCtx, entry)
+		if deliverErr != nil {
+			blocked[event.ItemID] = true
+
+			if len(delivered) > len(entry.DeliveredSinks) {
+				if err := r.outbox.RecordDeliveredSinks(ctx, entry.Sequence, delivered); err != nil {
+					return handled, err
+				}
+			}
+
+			attempt := entry.Attempts + 1
+			if attempt >= r.backoff.MaxAttempts {
+				slog.Error("event delivery failed for good, parking it",
+					"event_id", event.ID,
+					"item_id", event.ItemID,
+					"attempts", attempt,
+					"error", deliverErr,
+				)
+				outboxMetrics.Add("parked", 1)
+
+				if err := r.outbox.Park(ctx, entry.Sequence, deliverErr); err != nil {
+					return handled, err
+				}
+				continue
+			}
+
+			delay := r.backoff.Backoff(attempt)
+			slog.Warn("event delivery failed, will retry",
+				"event_id", event.ID,
+				"item_id", event.ItemID,
+				"attempt", attempt,
+				"retry_in", delay,
+				"error", deliverErr,
+			)
+
+			if err := r.outbox.MarkFailed(ctx, entry.Sequence, deliverErr, time.Now().Add(delay)); err != nil {
+				return handled, err
+			}
+			continue
+		}
+
+		if err := r.outbox.MarkSent(ctx, entry.Sequence); err != nil {
+			return handled, err
+		}
+	}
+
+	// blocked events stay behind their failed predecessor via FetchPending's ordering check
+	if err := r.outbox.Reschedule(ctx, sequences(skipped), time.Now()); err != nil {
+		return handled, err
+	}
+
+	if deliverCtx.Err() != nil && ctx.Err() == nil {
+		slog.Warn("outbox batch ran out of lease", "lease", r.lease, "handled", handled, "remaining", len(skipped))
+	}
+
+	return handled, nil
+}
+
+func (r *OutboxRelay) deliver(ctx context.Context, entry *domain.OutboxEntry) ([]string, error) {
+	if set, ok := r.sink.(sinkSet); ok {
+		return set.PublishExcept(ctx, entry.Event, entry.DeliveredSinks)
+	}
+	return nil, r.sink.Publish(ctx, entry.Event)
+}
+
+// reportParked keeps parked events visible after the log line that parked
+// them has scrolled away
+func (r *OutboxRelay) reportParked(ctx context.Context) {
+	parked, err := r.outbox.CountParked(ctx)
+	if err != nil {
+		slog.Error("failed to count parked outbox events", "error", err)
+		return
+	}
+
+	total := new(expvar.Int)
+	total.Set(parked)
+	outboxMetrics.Set("parked_total", total)
+
+	if parked > 0 {
+		slog.Warn("outbox has parked events that will not be delivered", "count", parked)
+	}
+}
+
+func sequences(entries []*domain.OutboxEntry) []int64 {
+	ids := make([]int64, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.Sequence
+	}
+	return ids
+}
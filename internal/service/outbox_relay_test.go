@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/google/uuid"
+)
+
+type outboxRow struct {
+	entry  domain.OutboxEntry
+	sent   bool
+	parked bool
+}
+
+// memOutbox follows the postgres repo's FetchPending ordering rules
+type memOutbox struct {
+	mu        sync.Mutex
+	rows      []*outboxRow
+	unclaimed bool
+}
+
+func (o *memOutbox) add(itemID uuid.UUID, attempts int) *domain.Event {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	event := domain.NewEvent(uuid.New(), itemID, domain.EventTypeTransactionsAdded, []byte(`{}`), "trace")
+	o.rows = append(o.rows, &outboxRow{entry: domain.OutboxEntry{
+		Sequence: int64(len(o.rows) + 1),
+		Event:    event,
+		Attempts: attempts,
+	}})
+	return event
+}
+
+func (o *memOutbox) row(id int64) *outboxRow {
+	return o.rows[id-1]
+}
+
+func (o *memOutbox) ClaimRelay(ctx context.Context) (bool, error) {
+	return !o.unclaimed, nil
+}
+
+func (o *memOutbox) FetchPending(ctx context.Context, limit int) ([]*domain.OutboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	waiting := func(r *outboxRow) bool { return !r.sent && !r.parked }
+
+	var entries []*domain.OutboxEntry
+	for i, r := range o.rows {
+		if !waiting(r) || r.entry.NextAttemptAt.After(now) {
+			continue
+		}
+
+		held := false
+		for _, earlier := range o.rows[:i] {
+			if waiting(earlier) && earlier.entry.Event.ItemID == r.entry.Event.ItemID && earlier.entry.NextAttemptAt.After(now) {
+				held = true
+				break
+			}
+		}
+		if held {
+			continue
+		}
+
+		entry := r.entry
+		entries = append(entries, &entry)
+		if len(entries) == limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+func (o *memOutbox) Reschedule(ctx context.Context, ids []int64, at time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, id := range ids {
+		if r := o.row(id); !r.sent {
+			r.entry.NextAttemptAt = at
+		}
+	}
+	return nil
+}
+
+func (o *memOutbox) MarkSent(ctx context.Context, id int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	r := o.row(id)
+	r.sent = true
+	r.entry.Attempts++
+	return nil
+}
+
+func (o *memOutbox) MarkFailed(ctx context.Context, id int64, deliveryErr error, nextAttemptAt time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	r := o.row(id)
+	r.entry.Attempts++
+	r.entry.NextAttemptAt = nextAttemptAt
+	return nil
+}
+
+func (o *memOutbox) RecordDeliveredSinks(ctx context.Context, id int64, sinks []string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.row(id).entry.DeliveredSinks = sinks
+	return nil
+}
+
+func (o *memOutbox) Park(ctx context.Context, id int64, deliveryErr error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	r := o.row(id)
+	r.entry.Attempts++
+	r.parked = true
+	return nil
+}
+
+func (o *memOutbox) CountParked(ctx context.Context) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var n int64
+	for _, r := range o.rows {
+		if r.parked {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (o *memOutbox) DeleteSentBefore(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+type inlineTx struct{}
+
+func (inlineTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// itemSink fails every event for the items in failing
+type itemSink struct {
+	failing   map[uuid.UUID]bool
+	delivered []uuid.UUID
+}
+
+func (s *itemSink) Publish(ctx context.Context, events ...*domain.Event) error {
+	for _, e := range events {
+		if s.failing[e.ItemID] {
+			return errors.New("sink rejected event")
+		}
+		s.delivered = append(s.delivered, e.ID)
+	}
+	return nil
+}
+
+func newTestRelay(outbox *memOutbox, sink *itemSink, maxAttempts int) *OutboxRelay {
+	return NewOutboxRelay(outbox, inlineTx{}, sink, 10, time.Second, time.Hour, time.Minute, time.Minute, maxAttempts)
+}
+
+func TestRelayBatchDeliversInOrder(t *testing.T) {
+	outbox := &memOutbox{}
+	item := uuid.New()
+	first := outbox.add(item, 0)
+	second := outbox.add(item, 0)
+
+	sink := &itemSink{}
+	handled, err := newTestRelay(outbox, sink, 5).RelayBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if handled != 2 || len(sink.delivered) != 2 || sink.delivered[0] != first.ID || sink.delivered[1] != second.ID {
+		t.Fatalf("handled %d, delivered %v, want both events in order", handled, sink.delivered)
+	}
+	if !outbox.row(1).sent || !outbox.row(2).sent {
+		t.Fatal("delivered events not marked sent")
+	}
+}
+
+func TestRelayBatchFailureHoldsBackItem(t *testing.T) {
+	outbox := &memOutbox{}
+	broken, healthy := uuid.New(), uuid.New()
+	outbox.add(broken, 0)
+	outbox.add(broken, 0)
+	ok := outbox.add(healthy, 0)
+
+	sink := &itemSink{failing: map[uuid.UUID]bool{broken: true}}
+	relay := newTestRelay(outbox, sink, 5)
+
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the other item isn't held up
+	if len(sink.delivered) != 1 || sink.delivered[0] != ok.ID {
+		t.Fatalf("delivered %v, want only the healthy item's event", sink.delivered)
+	}
+
+	failed := outbox.row(1)
+	if failed.entry.Attempts != 1 || !failed.entry.NextAttemptAt.After(time.Now()) {
+		t.Fatalf("failed event attempts %d next at %s, want a backed off retry", failed.entry.Attempts, failed.entry.NextAttemptAt)
+	}
+
+	// the second event waits behind the failed one, it isn't even fetched
+	sink.failing = nil
+	handled, err := relay.RelayBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handled != 0 || outbox.row(2).sent {
+		t.Fatalf("handled %d, event overtook its failed predecessor", handled)
+	}
+}
+
+func TestRelayBatchParksExhaustedEvent(t *testing.T) {
+	outbox := &memOutbox{}
+	item := uuid.New()
+	outbox.add(item, 2)
+	next := outbox.add(item, 0)
+
+	sink := &itemSink{failing: map[uuid.UUID]bool{item: true}}
+	relay := newTestRelay(outbox, sink, 3)
+
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	parked := outbox.row(1)
+	if !parked.parked || parked.sent || parked.entry.Attempts != 3 {
+		t.Fatalf("parked %v sent %v attempts %d, want parked after 3 attempts", parked.parked, parked.sent, parked.entry.Attempts)
+	}
+
+	// the parked event no longer blocks the item
+	sink.failing = nil
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.delivered) != 1 || sink.delivered[0] != next.ID {
+		t.Fatalf("delivered %v, want the event behind the parked one", sink.delivered)
+	}
+
+	if n, _ := outbox.CountParked(context.Background()); n != 1 {
+		t.Fatalf("parked count = %d, want 1", n)
+	}
+}
+
+func TestRelayBatchWithoutClaimDoesNothing(t *testing.T) {
+	outbox := &memOutbox{unclaimed: true}
+	outbox.add(uuid.New(), 0)
+
+	sink := &itemSink{}
+	handled, err := newTestRelay(outbox, sink, 5).RelayBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handled != 0 || len(sink.delivered) != 0 {
+		t.Fatalf("handled %d while another relay holds the claim", handled)
+	}
+}
+
+func TestRelayBatchRetriesOnlyFailedSinks(t *testing.T) {
+	outbox := &memOutbox{}
+	outbox.add(uuid.New(), 0)
+
+	failing := &flakyPublisher{failures: 1}
+	healthy := &flakyPublisher{}
+	fanOut := NewFanOutPublisher([]Sink{
+		{Name: "test-relay-failing", Publisher: failing, Policy: FailurePolicyBlock},
+		{Name: "test-relay-healthy", Publisher: healthy, Policy: FailurePolicyBlock},
+	}, RetryPolicy{MaxAttempts: 1})
+	relay := NewOutboxRelay(outbox, inlineTx{}, fanOut, 10, time.Second, time.Hour, time.Minute, time.Minute, 5)
+
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	row := outbox.row(1)
+	if row.sent || len(row.entry.DeliveredSinks) != 1 || row.entry.DeliveredSinks[0] != "test-relay-healthy" {
+		t.Fatalf("sent %v delivered sinks %v, want a retry remembering the healthy sink", row.sent, row.entry.DeliveredSinks)
+	}
+
+	// make the retry due
+	row.entry.NextAttemptAt = time.Now()
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !row.sent {
+		t.Fatal("event not sent after the failed sink recovered")
+	}
+	if calls, _ := healthy.snapshot(); calls != 1 {
+		t.Fatalf("healthy sink got the event %d times, want 1", calls)
+	}
+	if _, delivered := failing.snapshot(); len(delivered) != 1 {
+		t.Fatalf("failing sink got %d events on retry, want 1", len(delivered))
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+var ErrUnknownItem = errors.New("webhook for unknown item")
+
+// PlaidWebhookService turns verified plaid webhooks into item updates and jobs
+type PlaidWebhookService struct {
+	itemRepo  ports.ItemRepository
+	queue     ports.JobQueue
+	txManager ports.TxManager
+	publisher ports.EventPublisher
+	events    *EventBuilder
+}
+
+func NewPlaidWebhookService(
+	itemRepo ports.ItemRepository,
+	queue ports.JobQueue,
+	txManager ports.TxManager,
+	publisher ports.EventPublisher,
+	events *EventBuilder,
+) *PlaidWebhookService {
+	return &PlaidWebhookService{
+		itemRepo:  itemRepo,
+		queue:     queue,
+		txManager: txManager,
+		publisher: publisher,
+		events:    events,
+	}
+}
+
+func (s *PlaidWebhookService) Handle(ctx context.Context, payload *ports.WebhookPayload) error {
+	switch payload.WebhookType {
+	case "TRANSACTIONS":
+		return s.handleTransactions(ctx, payload)
+	case "ITEM":
+		return s.handleItem(ctx, payload)
+	default:
+		slog.Debug("ignoring webhook", "type", payload.WebhookType, "code", payload.WebhookCode)
+		return nil
+	}
+}
+
+func (s *PlaidWebhookService) handleTransactions(ctx context.Context, payload *ports.WebhookPayload) error {
+	var initialComplete, historicalComplete bool
+
+	switch payload.WebhookCode {
+	case "SYNC_UPDATES_AVAILABLE":
+		initialComplete = payload.InitialUpdateComplete != nil && *payload.InitialUpdateComplete
+		historicalComplete = payload.HistoricalUpdateComplete != nil && *payload.HistoricalUpdateComplete
+	case "INITIAL_UPDATE":
+		initialComplete = true
+	case "HISTORICAL_UPDATE":
+		initialComplete = true
+		historicalComplete = true
+	case "DEFAULT_UPDATE":
+	case "TRANSACTIONS_REMOVED":
+		// payload.RemovedTransactions is deliberately not applied here. every
+		// id in it also comes back in the removed list of /transactions/sync,
+		// so the sync job enqueued below marks the rows removed and emits the
+		// removal events, under the item's lock and together with the cursor.
+		// writing them from here would race that sync and could emit the
+		// removals twice. until the job runs the rows still read as active.
+		slog.Info("plaid reported removed transactions", "plaid_item_id", payload.ItemID, "count", len(payload.RemovedTransactions))
+	default:
+		slog.Debug("ignoring webhook", "type", payload.WebhookType, "code", payload.WebhookCode)
+		return nil
+	}
+
+	item, err := s.lookupItem(ctx, payload.ItemID)
+	if err != nil {
+		return err
+	}
+
+	if item.IsRevoked() || item.IsRemoved() {
+		slog.Info("ignoring webhook for disconnected item", "item_id", item.ID, "status", item.SyncStatus, "code", payload.WebhookCode)
+		return nil
+	}
+
+	if initialComplete || historicalComplete {
+		if err := s.itemRepo.SetUpdateFlags(ctx, item.ID, initialComplete, historicalComplete); err != nil {
+			return fmt.Errorf("failed to record update flags: %w", err)
+		}
+	}
+
+	// the job would only fail CanSync and end up dead-lettered, the repair
+	// enqueues a sync that catches up on these updates
+	if !item.CanSync() {
+		slog.Info("not syncing item in error", "item_id", item.ID, "code", payload.WebhookCode)
+		return nil
+	}
+
+	return s.enqueueSync(ctx, item, payload)
+}
+
+func (s *PlaidWebhookService) handleItem(ctx context.Context, payload *ports.WebhookPayload) error {
+	var eventType domain.EventType
+	// transition updates the loaded copy for the event, persist writes the
+	// same transition to the row
+	var transition func(item *domain.Item)
+	var persist func(ctx context.Context, item *domain.Item) error
+
+	switch payload.WebhookCode {
+	case "ERROR":
+		code, message := "UNKNOWN", "plaid reported an item error"
+		if payload.Error != nil {
+			code, message = payload.Error.ErrorCode, payload.Error.ErrorMessage
+		}
+		eventType = domain.EventTypeItemError
+		itemErr := domain.ItemError{
+			Code:     code,
+			Category: domain.ClassifyPlaidErrorCode(code),
+			Message:  message,
+		}
+		transition = func(item *domain.Item) { item.MarkError(itemErr) }
+		persist = func(ctx context.Context, item *domain.Item) error {
+			return s.itemRepo.ReportError(ctx, item.ID, itemErr)
+		}
+	case "LOGIN_REPAIRED":
+		eventType = domain.EventTypeItemLoginRepaired
+		transition = func(item *domain.Item) { item.MarkActive() }
+		persist = func(ctx context.Context, item *domain.Item) error {
+			return s.itemRepo.Reactivate(ctx, item.ID)
+		}
+	case "PENDING_EXPIRATION":
+		if payload.ConsentExpirationTime == nil {
+			slog.Warn("pending expiration webhook without an expiration time", "plaid_item_id", payload.ItemID)
+			return nil
+		}
+		expiresAt := *payload.ConsentExpirationTime
+		eventType = domain.EventTypeItemPendingExpiration
+		transition = func(item *domain.Item) { item.SetConsentExpiration(expiresAt) }
+		persist = func(ctx context.Context, item *domain.Item) error {
+			return s.itemRepo.SetConsentExpiration(ctx, item.ID, expiresAt)
+		}
+	case "USER_PERMISSION_REVOKED":
+		eventType = domain.EventTypeItemPermissionRevoked
+		transition = func(item *domain.Item) { item.MarkRevoked() }
+		persist = func(ctx context.Context, item *domain.Item) error {
+			return s.itemRepo.Revoke(ctx, item.ID)
+		}
+	case "WEBHOOK_UPDATE_ACKNOWLEDGED":
+		slog.Info("plaid acknowledged webhook url update", "plaid_item_id", payload.ItemID, "new_webhook_url", payload.NewWebhookURL)
+		return nil
+	default:
+		slog.Debug("ignoring webhook", "type", payload.WebhookType, "code", payload.WebhookCode)
+		return nil
+	}
+
+	item, err := s.lookupItem(ctx, payload.ItemID)
+	if err != nil {
+		return err
+	}
+
+	// a revoked or removed item has no token left, nothing can bring it back
+	if item.IsRevoked() || item.IsRemoved() {
+		slog.Info("ignoring webhook for disconnected item", "item_id", item.ID, "status", item.SyncStatus, "code", payload.WebhookCode)
+		return nil
+	}
+
+	transition(item)
+
+	event, err := s.events.ItemLifecycle(item, uuid.NewString(), eventType)
+	if err != nil {
+		return err
+	}
+
+	// the new state and its event commit together
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := persist(ctx, item); err != nil {
+			return err
+		}
+		if err := s.publisher.Publish(ctx, event); err != nil {
+			return fmt.Errorf("failed to publish item event: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, ports.ErrItemInactive) {
+		slog.Info("ignoring webhook for item disconnected since it was loaded", "item_id", item.ID, "code", payload.WebhookCode)
+		return nil
+	}
+	if err != nil {
+		// ErrItemStateChanged included, plaid redelivers once the item settles
+		return err
+	}
+
+	slog.Info("item state updated from webhook", "item_id", item.ID, "code", payload.WebhookCode, "status", item.SyncStatus)
+
+	// catch up on whatever was missed while the login was broken
+	if payload.WebhookCode == "LOGIN_REPAIRED" {
+		return s.enqueueSync(ctx, item, payload)
+	}
+
+	return nil
+}
+
+func (s *PlaidWebhookService) lookupItem(ctx context.Context, plaidItemID string) (*domain.Item, error) {
+	item, err := s.itemRepo.GetByPlaidItemID(ctx, plaidItemID)
+	if errors.Is(err, ports.ErrItemNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownItem, plaidItemID)
+	}
+	if err != nil {
+		// anything else may be temporary, fail so plaid redelivers
+		return nil, fmt.Errorf("failed to load item %s: %w", plaidItemID, err)
+	}
+	return item, nil
+}
+
+func (s *PlaidWebhookService) enqueueSync(ctx context.Context, item *domain.Item, payload *ports.WebhookPayload) error {
+	job := &domain.SyncJob{
+		ItemID:  item.ID,
+		JobType: domain.JobTypeStandard,
+		TraceID: uuid.NewString(),
+	}
+
+	if err := s.queue.Enqueue(ctx, job); err != nil {
+		return fmt.Errorf("failed to enqueue sync job: %w", err)
+	}
+
+	slog.Info("sync job enqueued", "item_id", item.ID, "webhook_type", payload.WebhookType, "webhook_code", payload.WebhookCode)
+	return nil
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Principal is whoever an authenticated request acts for
+type Principal struct {
+	TenantID uuid.UUID
+	// set when an end user's session token authenticated the request,
+	// they may only act as themselves
+	EndUserID string
+	// what authenticated the request, e.g. "api_key:<id>"
+	Subject string
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
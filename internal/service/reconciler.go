@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+type Reconciler struct {
+	itemRepo      ports.ItemRepository
+	txRepo        ports.TransactionRepository
+	txManager     ports.TxManager
+	reportRepo    ports.ReconciliationRepository
+	plaid         ports.PlaidClient
+	lock          ports.DistributedLock
+	publisher     ports.EventPublisher
+	events        *EventBuilder
+	globalLimiter ports.RateLimiter
+	itemLimiter   ports.RateLimiter
+	window        time.Duration
+	lockTTL       time.Duration
+}
+
+func NewReconciler(
+	itemRepo ports.ItemRepository,
+	txRepo ports.TransactionRepository,
+	txManager ports.TxManager,
+	reportRepo ports.ReconciliationRepository,
+	plaid ports.PlaidClient,
+	lock ports.DistributedLock,
+	publisher ports.EventPublisher,
+	events *EventBuilder,
+	globalLimiter ports.RateLimiter,
+	itemLimiter ports.RateLimiter,
+	window time.Duration,
+	lockTTL time.Duration,
+) *Reconciler {
+	return &Reconciler{
+		itemRepo:      itemRepo,
+		txRepo:        txRepo,
+		txManager:     txManager,
+		reportRepo:    reportRepo,
+		plaid:         plaid,
+		lock:          lock,
+		publisher:     publisher,
+		events:        events,
+		globalLimiter: globalLimiter,
+		itemLimiter:   itemLimiter,
+		window:        window,
+		lockTTL:       lockTTL,
+	}
+}
+
+func (r *Reconciler) ReconcileItem(ctx context.Context, itemID uuid.UUID) (*domain.ReconciliationReport, error) {
+	// share the sync lock so a reconciliation never races a cursor sync
+	lockKey := syncLockKey(itemID)
+	lock, err := acquireLock(ctx, r.lock, lockKey, r.lockTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock for item %s: %w", itemID, err)
+	}
+	defer lock.release()
+
+	report, err := r.reconcile(lock.ctx, itemID, lock.FencingToken())
+	if lockErr := lock.err(); lockErr != nil {
+		return nil, lockErr
+	}
+	return report, err
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, itemID uuid.UUID, fence int64) (*domain.ReconciliationReport, error) {
+	// global rate limit
+	if err := waitRateLimit(ctx, r.globalLimiter, "plaid_client"); err != nil {
+		return nil, fmt.Errorf("global rate limit error: %w", err)
+	}
+
+	// item rate limit
+	itemKey := fmt.Sprintf("plaid_item:%s", itemID)
+	if err := waitRateLimit(ctx, r.itemLimiter, itemKey); err != nil {
+		return nil, fmt.Errorf("item rate limit error: %w", err)
+	}
+
+	// load item
+	item, err := r.itemRepo.GetByID(ctx, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load item: %w", err)
+	}
+
+	if !item.CanSync() {
+		return nil, fmt.Errorf("item %s is in status '%s' and cannot be reconciled", itemID, item.SyncStatus)
+	}
+
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	start := end.Add(-r.window)
+
+	upstream, err := r.plaid.FetchTransactions(ctx, item.AccessTokenEnc, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transactions from plaid: %w", err)
+	}
+
+	stored, err := r.txRepo.ListByDateRange(ctx, item.ID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored transactions: %w", err)
+	}
+
+	report := &domain.ReconciliationReport{
+		ID:          uuid.New(),
+		ItemID:      item.ID,
+		WindowStart: start,
+		WindowEnd:   end,
+		PlaidCount:  len(upstream),
+		StoredCount: len(stored),
+	}
+
+	missing, mismatched, phantomIDs := diffWindow(upstream, stored)
+	report.MissingCount = len(missing)
+	report.MismatchedCount = len(mismatched)
+	report.PhantomCount = len(phantomIDs)
+	report.PhantomTransactionIDs = phantomIDs
+
+	// repair rows we lost or that drifted and flag the phantoms
+	if err := r.repair(ctx, item, fence, report, missing, mismatched, stored); err != nil {
+		return nil, err
+	}
+
+	if err := r.reportRepo.Create(ctx, report); err != nil {
+		return nil, err
+	}
+
+	if report.HasDrift() {
+		slog.Warn("reconciliation found drift",
+			"item_id", item.ID,
+			"missing", report.MissingCount,
+			"mismatched", report.MismatchedCount,
+			"phantom", report.PhantomCount,
+		)
+	} else {
+		slog.Info("reconciliation clean", "item_id", item.ID, "transactions", report.PlaidCount)
+	}
+
+	return report, nil
+}
+
+func (r *Reconciler) repair(ctx context.Context, item *domain.Item, fence int64, report *domain.ReconciliationReport, missing, mismatched, stored []*domain.Transaction) error {
+	batchSize := len(missing) + len(mismatched)
+	if batchSize == 0 && len(report.PhantomTransactionIDs) == 0 && !hasPhantom(stored) {
+		return nil
+	}
+
+	batch := make([]*domain.Transaction, 0, batchSize)
+	for _, tx := range missing {
+		tx.ItemID = item.ID
+		batch = append(batch, tx)
+	}
+	for _, tx := range mismatched {
+		tx.ItemID = item.ID
+		batch = append(batch, tx)
+	}
+
+	return r.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		// a holder whose lock expired mustn't write over a newer sync
+		if err := r.itemRepo.AdvanceFence(ctx, item.ID, fence); err != nil {
+			return fmt.Errorf("failed to check fencing token: %w", err)
+		}
+
+		// also clears the flag on rows plaid returns again
+		if err := r.txRepo.SetPhantoms(ctx, item.ID, report.WindowStart, report.WindowEnd, report.PhantomTransactionIDs); err != nil {
+			return err
+		}
+
+		if batchSize == 0 {
+			return nil
+		}
+
+		if err := r.txRepo.UpsertBatch(ctx, batch); err != nil {
+			return fmt.Errorf("failed to repair transactions: %w", err)
+		}
+
+		previous := make(map[string]*domain.Transaction, len(stored))
+		for _, tx := range stored {
+			previous[tx.PlaidTransactionID] = tx
+		}
+
+		events, err := r.events.TransactionChanges(item, TraceIDFromContext(ctx), missing, mismatched, nil, previous)
+		if err != nil {
+			return err
+		}
+		if err := r.publisher.Publish(ctx, events...); err != nil {
+			return fmt.Errorf("failed to publish events: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func hasPhantom(txs []*domain.Transaction) bool {
+	for _, tx := range txs {
+		if tx.PhantomAt != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// diffWindow compares plaid's view of a date window with ours. removed rows
+// that plaid still returns count as missing, active rows plaid doesn't
+// return are phantoms.
+func diffWindow(upstream, stored []*domain.Transaction) (missing, mismatched []*domain.Transaction, phantomIDs []string) {
+	storedByID := make(map[string]*domain.Transaction, len(stored))
+	for _, tx := range stored {
+		storedByID[tx.PlaidTransactionID] = tx
+	}
+
+	upstreamIDs := make(map[string]struct{}, len(upstream))
+	for _, tx := range upstream {
+		upstreamIDs[tx.PlaidTransactionID] = struct{}{}
+
+		existing, ok := storedByID[tx.PlaidTransactionID]
+		switch {
+		case !ok || existing.IsRemoved:
+			missing = append(missing, tx)
+		case !existing.Matches(*tx):
+			mismatched = append(mismatched, tx)
+		}
+	}
+
+	for _, tx := range stored {
+		if tx.IsRemoved {
+			continue
+		}
+		if _, ok := upstreamIDs[tx.PlaidTransactionID]; !ok {
+			phantomIDs = append(phantomIDs, tx.PlaidTransactionID)
+		}
+	}
+
+	return missing, mismatched, phantomIDs
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+// fencedItems only implements the fencing check, the rest of the repository
+// isn't reached by a repair
+type fencedItems struct {
+	ports.ItemRepository
+	fence int64
+}
+
+func (r *fencedItems) AdvanceFence(ctx context.Context, id uuid.UUID, fencingToken int64) error {
+	if fencingToken < r.fence {
+		return ports.ErrStaleFencingToken
+	}
+	r.fence = fencingToken
+	return nil
+}
+
+type upsertRecorder struct {
+	ports.TransactionRepository
+	upserted []*domain.Transaction
+	phantoms []string
+	// SetPhantoms was called
+	flagged bool
+}
+
+func (r *upsertRecorder) UpsertBatch(ctx context.Context, txs []*domain.Transaction) error {
+	r.upserted = append(r.upserted, txs...)
+	return nil
+}
+
+func (r *upsertRecorder) SetPhantoms(ctx context.Context, itemID uuid.UUID, start, end time.Time, plaidTxIDs []string) error {
+	r.flagged = true
+	r.phantoms = plaidTxIDs
+	return nil
+}
+
+func posted(id string, amount int64) *domain.Transaction {
+	return &domain.Transaction{
+		PlaidTransactionID: id,
+		AccountID:          "acc-1",
+		AmountCents:        amount,
+		CurrencyCode:       "USD",
+		Date:               time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		Status:             domain.TransactionStatusPosted,
+	}
+}
+
+func ids(txs []*domain.Transaction) []string {
+	out := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		out = append(out, tx.PlaidTransactionID)
+	}
+	return out
+}
+
+func TestDiffWindow(t *testing.T) {
+	removed := posted("tx-removed", 500)
+	removed.IsRemoved = true
+	goneRemoved := posted("tx-gone-removed", 500)
+	goneRemoved.IsRemoved = true
+
+	upstream := []*domain.Transaction{
+		posted("tx-same", 100),
+		posted("tx-changed", 250),
+		posted("tx-new", 300),
+		posted("tx-removed", 500),
+	}
+	stored := []*domain.Transaction{
+		posted("tx-same", 100),
+		posted("tx-changed", 200),
+		removed,
+		posted("tx-phantom", 400),
+		goneRemoved,
+	}
+
+	missing, mismatched, phantomIDs := diffWindow(upstream, stored)
+
+	if got := ids(missing); !slices.Equal(got, []string{"tx-new", "tx-removed"}) {
+		t.Errorf("missing = %v, want [tx-new tx-removed]", got)
+	}
+	if got := ids(mismatched); !slices.Equal(got, []string{"tx-changed"}) {
+		t.Errorf("mismatched = %v, want [tx-changed]", got)
+	}
+	// rows we already removed aren't phantoms
+	if !slices.Equal(phantomIDs, []string{"tx-phantom"}) {
+		t.Errorf("phantoms = %v, want [tx-phantom]", phantomIDs)
+	}
+}
+
+func newTestReconciler(items *fencedItems, txs *upsertRecorder, publisher ports.EventPublisher) *Reconciler {
+	return NewReconciler(items, txs, inlineTx{}, nil, nil, nil, publisher, NewEventBuilder(1<<20), nil, nil, 30*24*time.Hour, time.Minute)
+}
+
+func TestReconcilerRepair(t *testing.T) {
+	items := &fencedItems{fence: 3}
+	txs := &upsertRecorder{}
+	publisher := &flakyPublisher{}
+	item := &domain.Item{ID: uuid.New(), TenantID: uuid.New()}
+
+	stored := []*domain.Transaction{posted("tx-changed", 200)}
+	missing := []*domain.Transaction{posted("tx-new", 300)}
+	mismatched := []*domain.Transaction{posted("tx-changed", 250)}
+
+	if err := newTestReconciler(items, txs, publisher).repair(context.Background(), item, 3, &domain.ReconciliationReport{}, missing, mismatched, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ids(txs.upserted); !slices.Equal(got, []string{"tx-new", "tx-changed"}) {
+		t.Fatalf("upserted = %v, want [tx-new tx-changed]", got)
+	}
+	for _, tx := range txs.upserted {
+		if tx.ItemID != item.ID {
+			t.Fatalf("repaired row %s not attached to the item", tx.PlaidTransactionID)
+		}
+	}
+	if _, delivered := publisher.snapshot(); len(delivered) != 2 {
+		t.Fatalf("published %d events, want added and modified", len(delivered))
+	}
+}
+
+func TestReconcilerRepairStaleFence(t *testing.T) {
+	// a newer lock holder already wrote with token 5
+	items := &fencedItems{fence: 5}
+	txs := &upsertRecorder{}
+	publisher := &flakyPublisher{}
+	item := &domain.Item{ID: uuid.New(), TenantID: uuid.New()}
+
+	err := newTestReconciler(items, txs, publisher).repair(context.Background(), item, 4, &domain.ReconciliationReport{}, []*domain.Transaction{posted("tx-new", 300)}, nil, nil)
+	if !errors.Is(err, ports.ErrStaleFencingToken) {
+		t.Fatalf("error = %v, want ErrStaleFencingToken", err)
+	}
+	if len(txs.upserted) != 0 {
+		t.Fatal("stale holder wrote transactions")
+	}
+	if calls, _ := publisher.snapshot(); calls != 0 {
+		t.Fatal("stale holder published events")
+	}
+}
+
+func TestReconcilerRepairNothingToDo(t *testing.T) {
+	items := &fencedItems{fence: 5}
+	txs := &upsertRecorder{}
+
+	// a clean window doesn't need the fence
+	if err := newTestReconciler(items, txs, &flakyPublisher{}).repair(context.Background(), &domain.Item{ID: uuid.New()}, 1, &domain.ReconciliationReport{}, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if txs.flagged {
+		t.Fatal("clean window touched phantom flags")
+	}
+}
+
+func TestReconcilerRepairFlagsPhantoms(t *testing.T) {
+	items := &fencedItems{fence: 3}
+	txs := &upsertRecorder{}
+	publisher := &flakyPublisher{}
+	item := &domain.Item{ID: uuid.New(), TenantID: uuid.New()}
+	report := &domain.ReconciliationReport{PhantomTransactionIDs: []string{"tx-phantom"}}
+
+	// nothing to upsert, the phantom still gets flagged under the fence
+	if err := newTestReconciler(items, txs, publisher).repair(context.Background(), item, 4, report, nil, nil, []*domain.Transaction{posted("tx-phantom", 400)}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(txs.phantoms, []string{"tx-phantom"}) || items.fence != 4 {
+		t.Fatalf("phantoms %v at fence %d, want [tx-phantom] at fence 4", txs.phantoms, items.fence)
+	}
+	if len(txs.upserted) != 0 {
+		t.Fatalf("upserted %v, want nothing", ids(txs.upserted))
+	}
+	if calls, _ := publisher.snapshot(); calls != 0 {
+		t.Fatal("flagging phantoms published events")
+	}
+
+	// a stale holder flags nothing
+	txs = &upsertRecorder{}
+	err := newTestReconciler(items, txs, publisher).repair(context.Background(), item, 3, report, nil, nil, nil)
+	if !errors.Is(err, ports.ErrStaleFencingToken) || txs.flagged {
+		t.Fatalf("error = %v flagged %v, want ErrStaleFencingToken and no flags", err, txs.flagged)
+	}
+}
+
+func TestReconcilerRepairClearsReturnedPhantom(t *testing.T) {
+	txs := &upsertRecorder{}
+	flagged := posted("tx-back", 100)
+	now := time.Now()
+	flagged.PhantomAt = &now
+
+	// plaid returns the row again, the window has no phantoms left
+	if err := newTestReconciler(&fencedItems{}, txs, &flakyPublisher{}).repair(context.Background(), &domain.Item{ID: uuid.New()}, 1, &domain.ReconciliationReport{}, nil, nil, []*domain.Transaction{flagged}); err != nil {
+		t.Fatal(err)
+	}
+	if !txs.flagged || len(txs.phantoms) != 0 {
+		t.Fatalf("flagged %v phantoms %v, want the flag cleared", txs.flagged, txs.phantoms)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+)
+
+// how long a job waits on a rate limiter before giving up and retrying later
+const rateLimitWaitTimeout = 30 * time.Second
+
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// IsTransient reports whether err is likely to go away on its own.
+func IsTransient(err error) bool {
+	return errors.Is(err, ports.ErrPlaidUnavailable) ||
+		errors.Is(err, ports.ErrLockBusy) ||
+		errors.Is(err, ports.ErrLockLost) ||
+		errors.Is(err, ports.ErrStaleFencingToken) ||
+		errors.Is(err, ports.ErrRateLimited)
+}
+
+// itemErrorFrom describes a sync failure for the item's error columns
+func itemErrorFrom(err error) domain.ItemError {
+	itemErr := domain.ItemError{
+		Category: domain.ErrorCategoryUnknown,
+		Message:  err.Error(),
+	}
+
+	var apiErr *ports.PlaidAPIError
+	if errors.As(err, &apiErr) {
+		itemErr.Code = apiErr.Code
+		itemErr.Category = domain.ClassifyPlaidErrorCode(apiErr.Code)
+		itemErr.Message = apiErr.Message
+	}
+
+	if itemErr.Category == domain.ErrorCategoryUnknown && IsTransient(err) {
+		itemErr.Category = domain.ErrorCategoryTransient
+	}
+
+	return itemErr
+}
+
+// NextRetry decides whether a failed job should run again and after how long.
+// only transient failures are retried, everything else needs a human or a
+// code fix and goes straight to the dead-letter queue.
+func (p RetryPolicy) NextRetry(job *domain.SyncJob, err error) (time.Duration, bool) {
+	if !IsTransient(err) || job.Attempts >= p.MaxAttempts {
+		return 0, false
+	}
+
+	return p.Backoff(job.Attempts), true
+}
+
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	// up to 20% jitter so retries from one outage don't land together
+	jitter := time.Duration(rand.Int64N(int64(delay)/5 + 1))
+	return delay + jitter
+}
+
+func waitRateLimit(ctx context.Context, limiter ports.RateLimiter, key string) error {
+	waitCtx, cancel := context.WithTimeout(ctx, rateLimitWaitTimeout)
+	defer cancel()
+
+	err := limiter.Wait(waitCtx, key)
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %s", ports.ErrRateLimited, key)
+	}
+
+	return err
+}
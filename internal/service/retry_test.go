@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"plaid unavailable", ports.ErrPlaidUnavailable, true},
+		{"wrapped lock busy", fmt.Errorf("failed to acquire lock: %w", ports.ErrLockBusy), true},
+		{"lock lost", ports.ErrLockLost, true},
+		{"stale fencing token", ports.ErrStaleFencingToken, true},
+		{"rate limited", ports.ErrRateLimited, true},
+		{"transient plaid code", &ports.PlaidAPIError{Code: "INSTITUTION_DOWN", Err: ports.ErrPlaidUnavailable}, true},
+		{"login required", &ports.PlaidAPIError{Code: "ITEM_LOGIN_REQUIRED"}, false},
+		{"item inactive", ports.ErrItemInactive, false},
+		{"cursor reset", ports.ErrCursorReset, false},
+		{"plain error", errors.New("boom"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	tests := []struct {
+		name      string
+		attempts  int
+		err       error
+		wantRetry bool
+		minDelay  time.Duration
+		maxDelay  time.Duration
+	}{
+		{"first transient failure", 1, ports.ErrPlaidUnavailable, true, time.Second, 1200 * time.Millisecond},
+		{"backoff doubles", 2, ports.ErrLockBusy, true, 2 * time.Second, 2400 * time.Millisecond},
+		{"attempts exhausted", 3, ports.ErrPlaidUnavailable, false, 0, 0},
+		{"permanent failure", 1, errors.New("boom"), false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &domain.SyncJob{Attempts: tt.attempts}
+
+			delay, retry := policy.NextRetry(job, tt.err)
+			if retry != tt.wantRetry {
+				t.Fatalf("retry = %v, want %v", retry, tt.wantRetry)
+			}
+			if delay < tt.minDelay || delay > tt.maxDelay {
+				t.Fatalf("delay = %s, want between %s and %s", delay, tt.minDelay, tt.maxDelay)
+			}
+		})
+	}
+}
+
+func TestBackoffCapsAtMaxDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	for attempt := 0; attempt <= 20; attempt++ {
+		// max delay plus the 20% jitter
+		if delay := policy.Backoff(attempt); delay < time.Second || delay > 6*time.Second {
+			t.Fatalf("Backoff(%d) = %s, want between 1s and 6s", attempt, delay)
+		}
+	}
+}
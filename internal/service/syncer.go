@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/alexchny/sync-relay/internal/domain"
@@ -13,55 +15,62 @@ import (
 type Syncer struct {
 	itemRepo      ports.ItemRepository
 	txRepo        ports.TransactionRepository
+	txManager     ports.TxManager
 	plaid         ports.PlaidClient
 	lock          ports.DistributedLock
 	publisher     ports.EventPublisher
+	events        *EventBuilder
 	globalLimiter ports.RateLimiter
 	itemLimiter   ports.RateLimiter
+	lockTTL       time.Duration
 }
 
 func NewSyncer(
 	itemRepo ports.ItemRepository,
 	txRepo ports.TransactionRepository,
+	txManager ports.TxManager,
 	plaid ports.PlaidClient,
 	lock ports.DistributedLock,
 	publisher ports.EventPublisher,
+	events *EventBuilder,
 	globalLimiter ports.RateLimiter,
 	itemLimiter ports.RateLimiter,
+	lockTTL time.Duration,
 ) *Syncer {
 	return &Syncer{
 		itemRepo:      itemRepo,
 		txRepo:        txRepo,
+		txManager:     txManager,
 		plaid:         plaid,
 		lock:          lock,
 		publisher:     publisher,
+		events:        events,
 		globalLimiter: globalLimiter,
 		itemLimiter:   itemLimiter,
+		lockTTL:       lockTTL,
 	}
 }
 
 func (s *Syncer) SyncItem(ctx context.Context, itemID uuid.UUID) error {
 	// acquire lock
-	lockKey := fmt.Sprintf("sync:lock:%s", itemID)
-	release, err := s.lock.Acquire(ctx, lockKey, 2*time.Minute)
+	lockKey := syncLockKey(itemID)
+	lock, err := acquireLock(ctx, s.lock, lockKey, s.lockTTL)
 	if err != nil {
 		return fmt.Errorf("failed to acquire lock for item %s: %w", itemID, err)
 	}
+	defer lock.release()
 
-	defer func() {
-		if err := release(); err != nil {
-			_ = err
-		}
-	}()
+	// everything below stops if the lock can't be renewed
+	ctx = lock.ctx
 
 	// global rate limit
-	if err := s.globalLimiter.Wait(ctx, "plaid_client"); err != nil {
+	if err := waitRateLimit(ctx, s.globalLimiter, "plaid_client"); err != nil {
 		return fmt.Errorf("global rate limit error: %w", err)
 	}
 
 	// item rate limit
 	itemKey := fmt.Sprintf("plaid_item:%s", itemID)
-	if err := s.itemLimiter.Wait(ctx, itemKey); err != nil {
+	if err := waitRateLimit(ctx, s.itemLimiter, itemKey); err != nil {
 		return fmt.Errorf("item rate limit error: %w", err)
 	}
 
@@ -77,15 +86,65 @@ func (s *Syncer) SyncItem(ctx context.Context, itemID uuid.UUID) error {
 	}
 
 	// execute sync loop
-	if err := s.processSyncLoop(ctx, item); err != nil {
-		_ = s.itemRepo.MarkError(ctx, item.ID, err)
+	fence := lock.FencingToken()
+	if err := s.runSync(ctx, item, fence); err != nil {
+		if lockErr := lock.err(); lockErr != nil {
+			err = lockErr
+		}
+
+		// disconnected mid-sync, its status must stay and there is nothing to retry
+		if errors.Is(err, ports.ErrItemInactive) {
+			slog.Info("item disconnected during sync, dropping results", "item_id", item.ID)
+			return nil
+		}
+		// a webhook moved the item out of a syncable status, it keeps that status
+		if errors.Is(err, ports.ErrItemStateChanged) {
+			slog.Info("item status changed during sync, dropping results", "item_id", item.ID)
+			return nil
+		}
+
+		itemErr := itemErrorFrom(err)
+		switch {
+		case IsTransient(err):
+			// retried by the queue, keep the item syncable. a resyncing item
+			// stays resyncing so the retry starts the replay over
+			_ = s.itemRepo.RecordTransientError(ctx, item.ID, itemErr, fence)
+		case item.IsResyncing() && itemErr.Category == domain.ErrorCategoryUnknown:
+			// not a verdict on the item, an error status would strand it
+			// halfway through the replay with nothing to move it on
+			_ = s.itemRepo.RecordTransientError(ctx, item.ID, itemErr, fence)
+		default:
+			_ = s.itemRepo.MarkError(ctx, item.ID, itemErr, fence)
+		}
 		return fmt.Errorf("sync loop failed: %w", err)
 	}
 
 	return nil
 }
 
-func (s *Syncer) processSyncLoop(ctx context.Context, item *domain.Item) error {
+func (s *Syncer) runSync(ctx context.Context, item *domain.Item, fence int64) error {
+	// a previous resync never finished, start it over
+	if item.IsResyncing() {
+		slog.Info("resuming interrupted resync", "item_id", item.ID)
+		return s.resyncLoop(ctx, item, fence)
+	}
+
+	err := s.processSyncLoop(ctx, item, fence)
+	if !errors.Is(err, ports.ErrCursorReset) {
+		return err
+	}
+
+	slog.Warn("plaid cursor reset required, starting full resync", "item_id", item.ID)
+
+	if err := s.itemRepo.MarkResyncing(ctx, item.ID, fence); err != nil {
+		return fmt.Errorf("failed to mark item resyncing: %w", err)
+	}
+	item.MarkResyncing()
+
+	return s.resyncLoop(ctx, item, fence)
+}
+
+func (s *Syncer) processSyncLoop(ctx context.Context, item *domain.Item, fence int64) error {
 	cursor := item.NextCursor
 
 	for {
@@ -95,55 +154,221 @@ func (s *Syncer) processSyncLoop(ctx context.Context, item *domain.Item) error {
 			return err
 		}
 
-		// handle removed transactions
-		if len(resp.Removed) > 0 {
-			if err := s.txRepo.MarkRemovedBatch(ctx, item.ID, resp.Removed); err != nil {
-				return fmt.Errorf("failed to mark removed transactions: %w", err)
+		// the page's rows and the cursor commit together or not at all
+		err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+			if err := s.applyPage(ctx, item, resp.Added, resp.Modified, resp.Removed); err != nil {
+				return err
+			}
+
+			// save cursor
+			if err := s.itemRepo.UpdateSuccess(ctx, item.ID, resp.NextCursor, fence); err != nil {
+				return fmt.Errorf("failed to update cursor: %w", err)
 			}
+
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 
-		// handle added & modified transactions
-		batchSize := len(resp.Added) + len(resp.Modified)
-		if batchSize > 0 {
-			batch := make([]*domain.Transaction, 0, batchSize)
+		// pagination check
+		if !resp.HasMore {
+			break
+		}
 
-			// link to Item
-			for _, tx := range resp.Added {
-				tx.ItemID = item.ID
-				batch = append(batch, tx)
-			}
-			for _, tx := range resp.Modified {
-				tx.ItemID = item.ID
-				batch = append(batch, tx)
-			}
+		// increment cursor
+		cursor = resp.NextCursor
+	}
 
-			// batch upsert
-			if err := s.txRepo.UpsertBatch(ctx, batch); err != nil {
-				return fmt.Errorf("failed to upsert batch: %w", err)
-			}
+	return nil
+}
+
+// resyncLoop replays the item's full history from an empty cursor and
+// reconciles it against the stored rows, so consumers only see real changes
+// instead of a wipe-and-reload. the cursor is saved once the replay is done.
+func (s *Syncer) resyncLoop(ctx context.Context, item *domain.Item, fence int64) error {
+	cursor := ""
+	seen := make(map[string]struct{})
+
+	for {
+		// fetch from Plaid
+		resp, err := s.plaid.FetchSyncUpdates(ctx, item.AccessTokenEnc, cursor)
+		if err != nil {
+			return err
 		}
 
-		// publish events
-		if batchSize > 0 || len(resp.Removed) > 0 {
-			if err := s.publisher.PublishSyncEvents(ctx, item.ID, resp.Added, resp.Modified, resp.Removed); err != nil {
-				// stop sync if failed event emits
-				return fmt.Errorf("failed to publish events: %w", err)
-			}
+		incoming := make([]*domain.Transaction, 0, len(resp.Added)+len(resp.Modified))
+		incoming = append(incoming, resp.Added...)
+		incoming = append(incoming, resp.Modified...)
+
+		for _, tx := range incoming {
+			seen[tx.PlaidTransactionID] = struct{}{}
+		}
+		for _, id := range resp.Removed {
+			delete(seen, id)
 		}
 
-		// save cursor
-		if err := s.itemRepo.UpdateSuccess(ctx, item.ID, resp.NextCursor); err != nil {
-			return fmt.Errorf("failed to update cursor: %w", err)
+		// only write rows that differ from what we already have
+		added, modified, err := s.diffAgainstStored(ctx, item.ID, incoming)
+		if err != nil {
+			return err
+		}
+
+		// the cursor only moves at the end, the fence keeps a holder that
+		// lost the lock from writing pages in the meantime
+		err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+			if err := s.itemRepo.AdvanceFence(ctx, item.ID, fence); err != nil {
+				return fmt.Errorf("failed to check fencing token: %w", err)
+			}
+			return s.applyPage(ctx, item, added, modified, resp.Removed)
+		})
+		if err != nil {
+			return err
 		}
 
+		cursor = resp.NextCursor
+
 		// pagination check
 		if !resp.HasMore {
 			break
 		}
+	}
 
-		// increment cursor
-		cursor = resp.NextCursor
+	// stored rows that plaid no longer returns were removed upstream
+	storedIDs, err := s.txRepo.ListActivePlaidIDs(ctx, item.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list stored transactions: %w", err)
+	}
+
+	var stale []string
+	for _, id := range storedIDs {
+		if _, ok := seen[id]; !ok {
+			stale = append(stale, id)
+		}
+	}
+
+	err = s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.applyPage(ctx, item, nil, nil, stale); err != nil {
+			return err
+		}
+
+		// save cursor, item goes back to active
+		if err := s.itemRepo.UpdateSuccess(ctx, item.ID, cursor, fence); err != nil {
+			return fmt.Errorf("failed to update cursor: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("resync complete", "item_id", item.ID, "transactions", len(seen), "removed", len(stale))
+	return nil
+}
+
+func (s *Syncer) diffAgainstStored(ctx context.Context, itemID uuid.UUID, incoming []*domain.Transaction) (added, modified []*domain.Transaction, err error) {
+	if len(incoming) == 0 {
+		return nil, nil, nil
+	}
+
+	ids := make([]string, 0, len(incoming))
+	for _, tx := range incoming {
+		ids = append(ids, tx.PlaidTransactionID)
+	}
+
+	stored, err := s.txRepo.GetByPlaidIDs(ctx, itemID, ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load stored transactions: %w", err)
+	}
+
+	storedByID := make(map[string]*domain.Transaction, len(stored))
+	for _, tx := range stored {
+		storedByID[tx.PlaidTransactionID] = tx
+	}
+
+	for _, tx := range incoming {
+		existing, ok := storedByID[tx.PlaidTransactionID]
+		switch {
+		case !ok:
+			added = append(added, tx)
+		case !existing.Matches(*tx):
+			modified = append(modified, tx)
+		}
+	}
+
+	return added, modified, nil
+}
+
+func (s *Syncer) applyPage(ctx context.Context, item *domain.Item, added, modified []*domain.Transaction, removed []string) error {
+	// capture the values we are about to overwrite for the change event
+	previous, err := s.loadPrevious(ctx, item.ID, modified, removed)
+	if err != nil {
+		return err
+	}
+
+	// handle removed transactions
+	if len(removed) > 0 {
+		if err := s.txRepo.MarkRemovedBatch(ctx, item.ID, removed); err != nil {
+			return fmt.Errorf("failed to mark removed transactions: %w", err)
+		}
+	}
+
+	// handle added & modified transactions
+	batchSize := len(added) + len(modified)
+	if batchSize > 0 {
+		batch := make([]*domain.Transaction, 0, batchSize)
+
+		// link to Item
+		for _, tx := range added {
+			tx.ItemID = item.ID
+			batch = append(batch, tx)
+		}
+		for _, tx := range modified {
+			tx.ItemID = item.ID
+			batch = append(batch, tx)
+		}
+
+		// batch upsert
+		if err := s.txRepo.UpsertBatch(ctx, batch); err != nil {
+			return fmt.Errorf("failed to upsert batch: %w", err)
+		}
+	}
+
+	// events go to the outbox in the same transaction as the rows
+	if batchSize > 0 || len(removed) > 0 {
+		events, err := s.events.TransactionChanges(item, TraceIDFromContext(ctx), added, modified, removed, previous)
+		if err != nil {
+			return err
+		}
+		if err := s.publisher.Publish(ctx, events...); err != nil {
+			return fmt.Errorf("failed to publish events: %w", err)
+		}
 	}
 
 	return nil
 }
+
+func (s *Syncer) loadPrevious(ctx context.Context, itemID uuid.UUID, modified []*domain.Transaction, removed []string) (map[string]*domain.Transaction, error) {
+	ids := make([]string, 0, len(modified)+len(removed))
+	for _, tx := range modified {
+		ids = append(ids, tx.PlaidTransactionID)
+	}
+	ids = append(ids, removed...)
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	stored, err := s.txRepo.GetByPlaidIDs(ctx, itemID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load previous transactions: %w", err)
+	}
+
+	previous := make(map[string]*domain.Transaction, len(stored))
+	for _, tx := range stored {
+		previous[tx.PlaidTransactionID] = tx
+	}
+
+	return previous, nil
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+// memItems follows the postgres repo's conditions for the writes a sync makes
+type memItems struct {
+	ports.ItemRepository
+
+	mu         sync.Mutex
+	item       domain.Item
+	fence      int64
+	markErrors int
+}
+
+func (r *memItems) GetByID(ctx context.Context, id uuid.UUID) (*domain.Item, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item := r.item
+	return &item, nil
+}
+
+// missReason mirrors updateMissReason
+func (r *memItems) missReason(fencingToken int64) error {
+	switch {
+	case r.item.IsRevoked() || r.item.IsRemoved():
+		return ports.ErrItemInactive
+	case r.fence > fencingToken:
+		return ports.ErrStaleFencingToken
+	default:
+		return ports.ErrItemStateChanged
+	}
+}
+
+func (r *memItems) UpdateSuccess(ctx context.Context, id uuid.UUID, cursor string, fencingToken int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if fencingToken < r.fence || !r.item.CanSync() {
+		return r.missReason(fencingToken)
+	}
+	r.fence = fencingToken
+	r.item.NextCursor = cursor
+	r.item.MarkActive()
+	r.item.ErrorCode = ""
+	r.item.ErrorCategory = ""
+	return nil
+}
+
+func (r *memItems) ReportError(ctx context.Context, id uuid.UUID, itemErr domain.ItemError) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.item.SyncStatus = domain.SyncStatusError
+	r.item.ErrorCode = itemErr.Code
+	r.item.ErrorCategory = itemErr.Category
+	return nil
+}
+
+func (r *memItems) MarkError(ctx context.Context, id uuid.UUID, itemErr domain.ItemError, fencingToken int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.markErrors++
+	return nil
+}
+
+func (r *memItems) RecordTransientError(ctx context.Context, id uuid.UUID, itemErr domain.ItemError, fencingToken int64) error {
+	return nil
+}
+
+// pagePlaid returns one page of one added transaction, running onFetch first
+type pagePlaid struct {
+	ports.PlaidClient
+	onFetch func()
+}
+
+func (p *pagePlaid) FetchSyncUpdates(ctx context.Context, accessToken, cursor string) (*ports.SyncResponse, error) {
+	if p.onFetch != nil {
+		p.onFetch()
+	}
+	return &ports.SyncResponse{
+		Added:      []*domain.Transaction{posted("tx-1", 100)},
+		NextCursor: "cursor-1",
+	}, nil
+}
+
+type staticLock struct{ token int64 }
+
+func (l staticLock) FencingToken() int64               { return l.token }
+func (l staticLock) Refresh(ctx context.Context) error { return nil }
+func (l staticLock) Release() error                    { return nil }
+func (l staticLock) Acquire(ctx context.Context, key string, ttl time.Duration) (ports.Lock, error) {
+	return l, nil
+}
+
+type openLimiter struct{}
+
+func (openLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	return true, 0, nil
+}
+
+func (openLimiter) Wait(ctx context.Context, key string) error { return nil }
+
+func newTestSyncer(items *memItems, plaid *pagePlaid) *Syncer {
+	return NewSyncer(items, &upsertRecorder{}, inlineTx{}, plaid, staticLock{token: 7}, &flakyPublisher{}, NewEventBuilder(1<<20), openLimiter{}, openLimiter{}, time.Minute)
+}
+
+func TestSyncItemSavesCursor(t *testing.T) {
+	items := &memItems{item: domain.Item{ID: uuid.New(), SyncStatus: domain.SyncStatusActive}}
+
+	if err := newTestSyncer(items, &pagePlaid{}).SyncItem(context.Background(), items.item.ID); err != nil {
+		t.Fatal(err)
+	}
+	if items.item.NextCursor != "cursor-1" || items.fence != 7 {
+		t.Fatalf("cursor %q fence %d, want cursor-1 at fence 7", items.item.NextCursor, items.fence)
+	}
+}
+
+func TestSyncItemKeepsErrorFromWebhookMidSync(t *testing.T) {
+	items := &memItems{item: domain.Item{ID: uuid.New(), SyncStatus: domain.SyncStatusActive}}
+
+	// an ITEM ERROR webhook lands while the page is being fetched
+	plaid := &pagePlaid{onFetch: func() {
+		_ = items.ReportError(context.Background(), items.item.ID, domain.ItemError{
+			Code:     "ITEM_LOGIN_REQUIRED",
+			Category: domain.ClassifyPlaidErrorCode("ITEM_LOGIN_REQUIRED"),
+		})
+	}}
+
+	if err := newTestSyncer(items, plaid).SyncItem(context.Background(), items.item.ID); err != nil {
+		t.Fatalf("sync = %v, want the results dropped quietly", err)
+	}
+
+	if items.item.SyncStatus != domain.SyncStatusError || items.item.ErrorCode != "ITEM_LOGIN_REQUIRED" {
+		t.Fatalf("status %s code %q, want the webhook's error kept", items.item.SyncStatus, items.item.ErrorCode)
+	}
+	if items.item.NextCursor != "" {
+		t.Fatalf("cursor moved to %q on an item in error", items.item.NextCursor)
+	}
+	if items.markErrors != 0 {
+		t.Fatal("sync overwrote the webhook's error")
+	}
+}
@@ -0,0 +1,16 @@
+package service
+
+import "context"
+
+type traceIDKey struct{}
+
+// WithTraceID attaches the trace id of the job being processed so events
+// emitted while handling it can be correlated.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
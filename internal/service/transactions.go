@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrInvalidTransactionQuery = errors.New("invalid transaction query")
+
+type TransactionPage struct {
+	Transactions []*domain.Transaction
+	// empty on the last page
+	NextCursor string
+}
+
+type TransactionService struct {
+	txRepo ports.TransactionRepository
+}
+
+func NewTransactionService(r ports.TransactionRepository) *TransactionService {
+	return &TransactionService{txRepo: r}
+}
+
+// List returns one page of the tenant's transactions. cursor is the
+// NextCursor of the previous page, empty for the first.
+func (s *TransactionService) List(ctx context.Context, tenantID uuid.UUID, filter ports.TransactionFilter, cursor string) (*TransactionPage, error) {
+	if filter.StartDate != nil && filter.EndDate != nil && filter.EndDate.Before(*filter.StartDate) {
+		return nil, fmt.Errorf("%w: end_date is before start_date", ErrInvalidTransactionQuery)
+	}
+	if filter.MinAmountCents != nil && filter.MaxAmountCents != nil && *filter.MaxAmountCents < *filter.MinAmountCents {
+		return nil, fmt.Errorf("%w: max_amount_cents is below min_amount_cents", ErrInvalidTransactionQuery)
+	}
+	if filter.Limit < 1 {
+		return nil, fmt.Errorf("%w: limit must be positive", ErrInvalidTransactionQuery)
+	}
+
+	if cursor != "" {
+		after, err := decodeTransactionCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = after
+	}
+
+	limit := filter.Limit
+	filter.TenantID = tenantID
+	// one extra row tells us whether there is another page
+	filter.Limit = limit + 1
+
+	txs, err := s.txRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TransactionPage{Transactions: txs}
+	if len(txs) > limit {
+		page.Transactions = txs[:limit]
+		last := page.Transactions[limit-1]
+		page.NextCursor = encodeTransactionCursor(ports.TransactionCursor{Date: last.Date, ID: last.ID})
+	}
+
+	return page, nil
+}
+
+type cursorPayload struct {
+	Date string    `json:"d"`
+	ID   uuid.UUID `json:"id"`
+}
+
+// cursors are opaque to clients so the ordering can change without breaking them
+func encodeTransactionCursor(c ports.TransactionCursor) string {
+	raw, _ := json.Marshal(cursorPayload{Date: c.Date.Format("2006-01-02"), ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeTransactionCursor(cursor string) (*ports.TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var p cursorPayload
+	if err := json.Unmarshal(raw, &p); err != nil || p.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+
+	date, err := time.Parse("2006-01-02", p.Date)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &ports.TransactionCursor{Date: date, ID: p.ID}, nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+func TestTransactionCursorRoundTrip(t *testing.T) {
+	want := ports.TransactionCursor{
+		Date: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+		ID:   uuid.New(),
+	}
+
+	got, err := decodeTransactionCursor(encodeTransactionCursor(want))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !got.Date.Equal(want.Date) || got.ID != want.ID {
+		t.Fatalf("cursor = %+v, want %+v", *got, want)
+	}
+}
+
+func TestDecodeTransactionCursorRejectsGarbage(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"d":"2024-03-15","id":"` + uuid.NewString() + `"}`))},
+		{"not json", encode("hello")},
+		{"missing id", encode(`{"d":"2024-03-15"}`)},
+		{"nil id", encode(`{"d":"2024-03-15","id":"` + uuid.Nil.String() + `"}`)},
+		{"bad date", encode(`{"d":"15/03/2024","id":"` + uuid.NewString() + `"}`)},
+		{"missing date", encode(`{"id":"` + uuid.NewString() + `"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeTransactionCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
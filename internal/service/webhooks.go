@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/alexchny/sync-relay/internal/netguard"
+	"github.com/alexchny/sync-relay/internal/ports"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidWebhookURL = errors.New("webhook url must be an absolute http or https url")
+var ErrInvalidEventType = errors.New("unknown event type")
+
+type WebhookService struct {
+	subscriptions ports.WebhookSubscriptionRepository
+	deliveries    ports.WebhookDeliveryRepository
+	// lets local development point subscriptions at localhost
+	allowPrivateTargets bool
+}
+
+func NewWebhookService(s ports.WebhookSubscriptionRepository, d ports.WebhookDeliveryRepository, allowPrivateTargets bool) *WebhookService {
+	return &WebhookService{
+		subscriptions:       s,
+		deliveries:          d,
+		allowPrivateTargets: allowPrivateTargets,
+	}
+}
+
+func (s *WebhookService) CreateSubscription(ctx context.Context, tenantID uuid.UUID, rawURL string, eventTypes []string) (*domain.WebhookSubscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+
+	// the relay must not be usable to reach our own network
+	if !s.allowPrivateTargets {
+		if err := netguard.CheckHost(ctx, u.Hostname()); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidWebhookURL, err)
+		}
+	}
+
+	if len(eventTypes) == 0 {
+		return nil, fmt.Errorf("%w: at least one event type is required", ErrInvalidEventType)
+	}
+
+	types := make([]domain.EventType, 0, len(eventTypes))
+	for _, t := range eventTypes {
+		if !domain.EventType(t).IsKnown() {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidEventType, t)
+		}
+		types = append(types, domain.EventType(t))
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &domain.WebhookSubscription{
+		ID:         uuid.New(),
+		TenantID:   tenantID,
+		URL:        u.String(),
+		EventTypes: types,
+		Secret:     secret,
+	}
+	if err := s.subscriptions.Create(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	slog.Info("webhook subscription created", "subscription_id", sub.ID, "tenant_id", tenantID)
+	return sub, nil
+}
+
+func (s *WebhookService) ListSubscriptions(ctx context.Context, tenantID uuid.UUID) ([]*domain.WebhookSubscription, error) {
+	return s.subscriptions.ListByTenant(ctx, tenantID)
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, tenantID, id uuid.UUID) error {
+	if err := s.subscriptions.Delete(ctx, tenantID, id); err != nil {
+		return err
+	}
+
+	slog.Info("webhook subscription deleted", "subscription_id", id, "tenant_id", tenantID)
+	return nil
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, tenantID, subscriptionID uuid.UUID, limit int) ([]*domain.WebhookDelivery, error) {
+	// scope the lookup to the tenant before exposing its log
+	if _, err := s.subscriptions.GetByID(ctx, tenantID, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	return s.deliveries.ListBySubscription(ctx, subscriptionID, limit)
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alexchny/sync-relay/internal/domain"
+	"github.com/google/uuid"
+)
+
+type memSubscriptions struct {
+	created []*domain.WebhookSubscription
+}
+
+func (m *memSubscriptions) Create(ctx context.Context, sub *domain.WebhookSubscription) error {
+	m.created = append(m.created, sub)
+	return nil
+}
+
+func (m *memSubscriptions) GetByID(ctx context.Context, tenantID, id uuid.UUID) (*domain.WebhookSubscription, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *memSubscriptions) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]*domain.WebhookSubscription, error) {
+	return m.created, nil
+}
+
+func (m *memSubscriptions) Delete(ctx context.Context, tenantID, id uuid.UUID) error {
+	return nil
+}
+
+func TestCreateSubscriptionValidation(t *testing.T) {
+	added := []string{string(domain.EventTypeTransactionsAdded)}
+
+	tests := []struct {
+		name         string
+		url          string
+		eventTypes   []string
+		allowPrivate bool
+		wantErr      error
+	}{
+		{"public target", "https://203.0.113.10/hooks", added, false, nil},
+		{"not http", "ftp://203.0.113.10/hooks", added, false, ErrInvalidWebhookURL},
+		{"relative", "/hooks", added, false, ErrInvalidWebhookURL},
+		{"loopback", "http://127.0.0.1:8080/hooks", added, false, ErrInvalidWebhookURL},
+		{"private", "http://10.0.0.5/hooks", added, false, ErrInvalidWebhookURL},
+		{"metadata", "http://169.254.169.254/latest/meta-data", added, false, ErrInvalidWebhookURL},
+		{"ipv6 loopback", "http://[::1]/hooks", added, false, ErrInvalidWebhookURL},
+		{"private allowed", "http://127.0.0.1:8080/hooks", added, true, nil},
+		{"no event types", "https://203.0.113.10/hooks", nil, false, ErrInvalidEventType},
+		{"unknown event type", "https://203.0.113.10/hooks", []string{"transactions.exploded"}, false, ErrInvalidEventType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs := &memSubscriptions{}
+			svc := NewWebhookService(subs, nil, tt.allowPrivate)
+
+			sub, err := svc.CreateSubscription(context.Background(), uuid.New(), tt.url, tt.eventTypes)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if len(subs.created) != 0 {
+					t.Fatal("rejected subscription was stored")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(subs.created) != 1 || sub.Secret == "" {
+				t.Fatalf("subscription %+v not stored with a secret", sub)
+			}
+		})
+	}
+}
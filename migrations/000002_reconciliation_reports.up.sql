@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+    id UUID PRIMARY KEY,
+    item_id UUID NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    window_start DATE NOT NULL,
+    window_end DATE NOT NULL,
+    plaid_count INT NOT NULL,
+    stored_count INT NOT NULL,
+    missing_count INT NOT NULL,
+    mismatched_count INT NOT NULL,
+    phantom_count INT NOT NULL,
+    phantom_transaction_ids TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_reports_item_id ON reconciliation_reports(item_id, created_at DESC);
@@ -0,0 +1,2 @@
+ALTER TABLE items DROP COLUMN IF EXISTS historical_update_complete;
+ALTER TABLE items DROP COLUMN IF EXISTS initial_update_complete;
@@ -0,0 +1,6 @@
+ALTER TABLE items DROP CONSTRAINT IF EXISTS chk_sync_status;
+UPDATE items SET sync_status = 'error' WHERE sync_status = 'revoked';
+ALTER TABLE items ADD CONSTRAINT chk_sync_status CHECK (sync_status IN ('active', 'error', 'resyncing'));
+
+ALTER TABLE items DROP COLUMN IF EXISTS consent_expires_at;
+ALTER TABLE items DROP COLUMN IF EXISTS error_code;
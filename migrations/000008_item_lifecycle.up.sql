@@ -0,0 +1,5 @@
+ALTER TABLE items ADD COLUMN IF NOT EXISTS error_code TEXT;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS consent_expires_at TIMESTAMPTZ;
+
+ALTER TABLE items DROP CONSTRAINT IF EXISTS chk_sync_status;
+ALTER TABLE items ADD CONSTRAINT chk_sync_status CHECK (sync_status IN ('active', 'error', 'resyncing', 'revoked'));
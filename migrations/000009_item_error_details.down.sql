@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_items_error_category;
+ALTER TABLE items DROP CONSTRAINT IF EXISTS chk_error_category;
+ALTER TABLE items DROP COLUMN IF EXISTS error_retry_count;
+ALTER TABLE items DROP COLUMN IF EXISTS error_first_seen_at;
+ALTER TABLE items DROP COLUMN IF EXISTS error_category;
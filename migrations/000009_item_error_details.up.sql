@@ -0,0 +1,12 @@
+ALTER TABLE items ADD COLUMN IF NOT EXISTS error_category TEXT;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS error_first_seen_at TIMESTAMPTZ;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS error_retry_count INT NOT NULL DEFAULT 0;
+
+-- errors recorded before categories existed
+UPDATE items SET error_category = 'unknown', error_first_seen_at = updated_at
+WHERE sync_status = 'error' AND error_category IS NULL;
+
+ALTER TABLE items ADD CONSTRAINT chk_error_category
+    CHECK (error_category IN ('user_action', 'transient', 'config', 'unknown'));
+
+CREATE INDEX IF NOT EXISTS idx_items_error_category ON items(error_category) WHERE error_category IS NOT NULL;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_items_purge_after;
+
+ALTER TABLE items DROP CONSTRAINT IF EXISTS chk_sync_status;
+UPDATE items SET sync_status = 'revoked' WHERE sync_status = 'removed';
+ALTER TABLE items ADD CONSTRAINT chk_sync_status CHECK (sync_status IN ('active', 'error', 'resyncing', 'revoked'));
+
+ALTER TABLE items DROP COLUMN IF EXISTS purge_after;
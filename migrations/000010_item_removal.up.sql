@@ -0,0 +1,6 @@
+ALTER TABLE items ADD COLUMN IF NOT EXISTS purge_after TIMESTAMPTZ;
+
+ALTER TABLE items DROP CONSTRAINT IF EXISTS chk_sync_status;
+ALTER TABLE items ADD CONSTRAINT chk_sync_status CHECK (sync_status IN ('active', 'error', 'resyncing', 'revoked', 'removed'));
+
+CREATE INDEX IF NOT EXISTS idx_items_purge_after ON items(purge_after) WHERE purge_after IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_items_tenant_end_user;
+ALTER TABLE items DROP COLUMN IF EXISTS end_user_id;
+
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS tenants;
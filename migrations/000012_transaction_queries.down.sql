@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_transactions_date_id;
+DROP INDEX IF EXISTS idx_transactions_item_account;
+DROP INDEX IF EXISTS idx_transactions_item_date_id;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS account_id;
@@ -0,0 +1,15 @@
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS account_id TEXT;
+
+-- rows synced before the column existed carry it in the raw plaid payload
+UPDATE transactions
+SET account_id = raw_payload->>'account_id'
+WHERE account_id IS NULL AND raw_payload IS NOT NULL;
+
+-- keyset order of GET /api/transactions
+CREATE INDEX IF NOT EXISTS idx_transactions_item_date_id ON transactions(item_id, date DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_transactions_item_account ON transactions(item_id, account_id);
+
+-- tenant-wide GET /api/transactions without item_id: walk transactions in
+-- keyset order and check the tenant on the joined item, instead of sorting
+-- every row the tenant has
+CREATE INDEX IF NOT EXISTS idx_transactions_date_id ON transactions(date DESC, id DESC);
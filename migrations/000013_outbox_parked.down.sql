@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_outbox_parked;
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS parked_at;
@@ -0,0 +1,5 @@
+-- events that used up their delivery attempts, they no longer hold back
+-- later events for the item and wait for someone to look at them
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS parked_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_parked ON outbox(parked_at) WHERE parked_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- sinks that already took a failed event, its retries only go to the others
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS delivered_sinks TEXT[] NOT NULL DEFAULT '{}';
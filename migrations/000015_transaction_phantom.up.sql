@@ -0,0 +1,4 @@
+-- set by reconciliation while plaid no longer returns the row for its window
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS phantom_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_phantom ON transactions(item_id) WHERE phantom_at IS NOT NULL;
@@ -0,0 +1,322 @@
+package eventstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Message struct {
+	ID          string
+	Stream      string
+	ContentType string
+	// cloudevents attributes without the field prefix, empty for structured events
+	Attributes map[string]string
+	Body       []byte
+}
+
+// Handler processes one message. returning an error leaves the entry pending
+// so it is redelivered once it has been idle for MinIdle.
+type Handler func(ctx context.Context, msg *Message) error
+
+// DeadLetterHandler takes an entry the handler failed MaxDeliveries times.
+// the entry is acked once it returns nil, an error leaves it pending.
+type DeadLetterHandler func(ctx context.Context, msg *Message, deliveries int64) error
+
+type Config struct {
+	Stream   string
+	Group    string
+	Consumer string
+
+	// where a newly created group starts, "0" for the retained history or
+	// "$" for new entries only
+	StartID   string
+	BatchSize int64
+	Block     time.Duration
+	// pending entries idle this long are claimed from whichever consumer holds them
+	MinIdle time.Duration
+
+	// an entry delivered this many times without being handled goes to
+	// DeadLetter instead of being claimed again, 10 by default
+	MaxDeliveries int64
+	// without one, a given up entry is logged and acked
+	DeadLetter DeadLetterHandler
+}
+
+type Consumer struct {
+	rdb redis.UniversalClient
+	cfg Config
+}
+
+func NewConsumer(rdb redis.UniversalClient, cfg Config) *Consumer {
+	if cfg.StartID == "" {
+		cfg.StartID = "0"
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Block <= 0 {
+		cfg.Block = 5 * time.Second
+	}
+	if cfg.MinIdle <= 0 {
+		cfg.MinIdle = time.Minute
+	}
+	if cfg.MaxDeliveries <= 0 {
+		cfg.MaxDeliveries = 10
+	}
+
+	return &Consumer{rdb: rdb, cfg: cfg}
+}
+
+// Run consumes until ctx is cancelled. it first replays this consumer's own
+// pending entries, then alternates between claiming stale entries and
+// reading new ones.
+func (c *Consumer) Run(ctx context.Context, handle Handler) error {
+	if err := c.ensureGroup(ctx); err != nil {
+		return err
+	}
+
+	if err := c.drainOwnPending(ctx, handle); err != nil {
+		return err
+	}
+
+	lastClaim := time.Now()
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if time.Since(lastClaim) >= c.cfg.MinIdle {
+			if err := c.claimStale(ctx, handle); err != nil {
+				return err
+			}
+			lastClaim = time.Now()
+		}
+
+		streams, err := c.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.cfg.Group,
+			Consumer: c.cfg.Consumer,
+			Streams:  []string{c.cfg.Stream, ">"},
+			Count:    c.cfg.BatchSize,
+			Block:    c.cfg.Block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read stream %s: %w", c.cfg.Stream, err)
+		}
+
+		// first deliveries, no need to ask for the count
+		for _, stream := range streams {
+			if err := c.handleAll(ctx, handle, stream.Messages, nil); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *Consumer) ensureGroup(ctx context.Context) error {
+	err := c.rdb.XGroupCreateMkStream(ctx, c.cfg.Stream, c.cfg.Group, c.cfg.StartID).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s: %w", c.cfg.Group, err)
+	}
+	return nil
+}
+
+// entries delivered to this consumer before a restart but never acked
+func (c *Consumer) drainOwnPending(ctx context.Context, handle Handler) error {
+	start := "0"
+	for {
+		streams, err := c.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.cfg.Group,
+			Consumer: c.cfg.Consumer,
+			Streams:  []string{c.cfg.Stream, start},
+			Count:    c.cfg.BatchSize,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read pending entries: %w", err)
+		}
+		if len(streams) == 0 || len(streams[0].Messages) == 0 {
+			return nil
+		}
+
+		msgs := streams[0].Messages
+		deliveries, err := c.deliveryCounts(ctx, msgs)
+		if err != nil {
+			return err
+		}
+		if err := c.handleAll(ctx, handle, msgs, deliveries); err != nil {
+			return err
+		}
+		start = msgs[len(msgs)-1].ID
+	}
+}
+
+func (c *Consumer) claimStale(ctx context.Context, handle Handler) error {
+	start := "0-0"
+	for {
+		msgs, next, err := c.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   c.cfg.Stream,
+			Group:    c.cfg.Group,
+			Consumer: c.cfg.Consumer,
+			MinIdle:  c.cfg.MinIdle,
+			Start:    start,
+			Count:    c.cfg.BatchSize,
+		}).Result()
+		if err != nil {
+			return fmt.Errorf("failed to claim pending entries: %w", err)
+		}
+
+		if len(msgs) > 0 {
+			slog.Info("claimed stale stream entries", "stream", c.cfg.Stream, "count", len(msgs))
+		}
+		deliveries, err := c.deliveryCounts(ctx, msgs)
+		if err != nil {
+			return err
+		}
+		if err := c.handleAll(ctx, handle, msgs, deliveries); err != nil {
+			return err
+		}
+
+		// the scan wrapped around the pending list
+		if next == "0-0" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// deliveryCounts asks XPENDING how often each of this consumer's entries has
+// been delivered, XAUTOCLAIM and history reads both count as a delivery
+func (c *Consumer) deliveryCounts(ctx context.Context, msgs []redis.XMessage) (map[string]int64, error) {
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+
+	pending, err := c.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   c.cfg.Stream,
+		Group:    c.cfg.Group,
+		Start:    msgs[0].ID,
+		End:      msgs[len(msgs)-1].ID,
+		Count:    int64(len(msgs)),
+		Consumer: c.cfg.Consumer,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delivery counts: %w", err)
+	}
+
+	counts := make(map[string]int64, len(pending))
+	for _, p := range pending {
+		counts[p.ID] = p.RetryCount
+	}
+	return counts, nil
+}
+
+// handleAll runs handle on each entry. deliveries holds each entry's delivery
+// count, entries missing from it are on their first delivery.
+func (c *Consumer) handleAll(ctx context.Context, handle Handler, msgs []redis.XMessage, deliveries map[string]int64) error {
+	for _, m := range msgs {
+		msg := decode(c.cfg.Stream, m)
+
+		count, ok := deliveries[m.ID]
+		if !ok {
+			count = 1
+		}
+
+		// more deliveries than attempts means the handler never returned,
+		// e.g. it crashed the consumer
+		if count > c.cfg.MaxDeliveries {
+			if err := c.deadLetter(ctx, msg, count, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := handle(ctx, msg); err != nil {
+			if count >= c.cfg.MaxDeliveries {
+				if err := c.deadLetter(ctx, msg, count, err); err != nil {
+					return err
+				}
+				continue
+			}
+
+			slog.Warn("stream entry handler failed, leaving it pending",
+				"stream", c.cfg.Stream,
+				"entry_id", m.ID,
+				"deliveries", count,
+				"error", err,
+			)
+			continue
+		}
+
+		if err := c.ack(ctx, m.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deadLetter gives up on an entry, handleErr is the handler's last error if
+// it returned one
+func (c *Consumer) deadLetter(ctx context.Context, msg *Message, deliveries int64, handleErr error) error {
+	if c.cfg.DeadLetter == nil {
+		slog.Error("giving up on stream entry",
+			"stream", c.cfg.Stream,
+			"entry_id", msg.ID,
+			"deliveries", deliveries,
+			"error", handleErr,
+		)
+		return c.ack(ctx, msg.ID)
+	}
+
+	if err := c.cfg.DeadLetter(ctx, msg, deliveries); err != nil {
+		slog.Warn("dead letter handler failed, leaving the entry pending",
+			"stream", c.cfg.Stream,
+			"entry_id", msg.ID,
+			"error", err,
+		)
+		return nil
+	}
+	return c.ack(ctx, msg.ID)
+}
+
+func (c *Consumer) ack(ctx context.Context, id string) error {
+	if err := c.rdb.XAck(ctx, c.cfg.Stream, c.cfg.Group, id).Err(); err != nil {
+		return fmt.Errorf("failed to ack entry %s: %w", id, err)
+	}
+	return nil
+}
+
+func decode(stream string, m redis.XMessage) *Message {
+	msg := &Message{
+		ID:         m.ID,
+		Stream:     stream,
+		Attributes: map[string]string{},
+	}
+
+	for field, value := range m.Values {
+		s, _ := value.(string)
+		switch {
+		case field == FieldContentType:
+			msg.ContentType = s
+		case field == FieldBody:
+			msg.Body = []byte(s)
+		case strings.HasPrefix(field, FieldAttributePrefix):
+			msg.Attributes[strings.TrimPrefix(field, FieldAttributePrefix)] = s
+		}
+	}
+
+	return msg
+}
@@ -0,0 +1,153 @@
+package eventstream
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		want   Message
+	}{
+		{
+			name: "binary",
+			values: map[string]interface{}{
+				FieldContentType: "application/json",
+				FieldBody:        `{"changes":[]}`,
+				"ce_type":        "transactions.added",
+				"ce_id":          "evt-1",
+			},
+			want: Message{
+				ContentType: "application/json",
+				Attributes:  map[string]string{"type": "transactions.added", "id": "evt-1"},
+				Body:        []byte(`{"changes":[]}`),
+			},
+		},
+		{
+			name: "structured",
+			values: map[string]interface{}{
+				FieldContentType: "application/cloudevents+json",
+				FieldBody:        `{"specversion":"1.0"}`,
+			},
+			want: Message{
+				ContentType: "application/cloudevents+json",
+				Attributes:  map[string]string{},
+				Body:        []byte(`{"specversion":"1.0"}`),
+			},
+		},
+		{
+			// fields another writer added are ignored
+			name:   "unknown fields",
+			values: map[string]interface{}{"other": "x", FieldBody: "b"},
+			want:   Message{Attributes: map[string]string{}, Body: []byte("b")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := decode("events", redis.XMessage{ID: "1-0", Values: tt.values})
+
+			if msg.ID != "1-0" || msg.Stream != "events" {
+				t.Fatalf("id %q stream %q, want 1-0 on events", msg.ID, msg.Stream)
+			}
+			if msg.ContentType != tt.want.ContentType || string(msg.Body) != string(tt.want.Body) {
+				t.Fatalf("content type %q body %q, want %q %q", msg.ContentType, msg.Body, tt.want.ContentType, tt.want.Body)
+			}
+			if len(msg.Attributes) != len(tt.want.Attributes) {
+				t.Fatalf("attributes = %v, want %v", msg.Attributes, tt.want.Attributes)
+			}
+			for k, v := range tt.want.Attributes {
+				if msg.Attributes[k] != v {
+					t.Fatalf("attributes = %v, want %v", msg.Attributes, tt.want.Attributes)
+				}
+			}
+		})
+	}
+}
+
+// runs against a real redis: TEST_REDIS_ADDR=localhost:6379 go test ./pkg/eventstream
+func TestConsumerDeadLettersFailingEntry(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR not set")
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	stream := "test-stream:" + uuid.NewString()
+	t.Cleanup(func() {
+		_ = rdb.Del(context.Background(), stream).Err()
+		_ = rdb.Close()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: map[string]interface{}{FieldBody: "poison"}}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type deadLetter struct {
+		msg        *Message
+		deliveries int64
+	}
+	dead := make(chan deadLetter, 1)
+	calls := 0
+
+	consumer := NewConsumer(rdb, Config{
+		Stream:        stream,
+		Group:         "group",
+		Consumer:      "consumer-1",
+		Block:         20 * time.Millisecond,
+		MinIdle:       10 * time.Millisecond,
+		MaxDeliveries: 3,
+		DeadLetter: func(ctx context.Context, msg *Message, deliveries int64) error {
+			dead <- deadLetter{msg, deliveries}
+			return nil
+		},
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		// the error of a read cut short by the cancel doesn't matter here
+		_ = consumer.Run(ctx, func(ctx context.Context, msg *Message) error {
+			calls++
+			return errors.New("cannot handle")
+		})
+	}()
+
+	select {
+	case d := <-dead:
+		if d.msg.ID != id || string(d.msg.Body) != "poison" || d.deliveries != 3 {
+			t.Fatalf("dead letter %s %q after %d deliveries, want %s after 3", d.msg.ID, d.msg.Body, d.deliveries, id)
+		}
+	case <-ctx.Done():
+		t.Fatal("entry was never dead-lettered")
+	}
+
+	// give the ack time to land before stopping
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-stopped
+
+	if calls != 3 {
+		t.Fatalf("handler called %d times, want 3", calls)
+	}
+
+	pending, err := rdb.XPending(context.Background(), stream, "group").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Count != 0 {
+		t.Fatalf("%d entries still pending, want the dead letter acked", pending.Count)
+	}
+}
@@ -0,0 +1,23 @@
+// Package eventstream consumes sync-relay events from redis streams. entries
+// are read through a consumer group, acked once handled, and entries left
+// pending by a crashed consumer are claimed after they sit idle, so a
+// restarted service resumes where it left off. an entry that keeps failing
+// is handed to a dead letter handler after Config.MaxDeliveries deliveries.
+package eventstream
+
+// entry fields written by the relay's stream publisher
+const (
+	FieldContentType = "content_type"
+	FieldBody        = "body"
+	// cloudevents attributes in binary mode, e.g. ce_type
+	FieldAttributePrefix = "ce_"
+)
+
+// StreamKey returns the stream a tenant's events are written to. with
+// per-tenant streams disabled every tenant shares the prefix stream.
+func StreamKey(prefix, tenantID string, perTenant bool) string {
+	if !perTenant {
+		return prefix
+	}
+	return prefix + ":" + tenantID
+}
@@ -0,0 +1,19 @@
+package eventstream
+
+import "testing"
+
+func TestStreamKey(t *testing.T) {
+	tests := []struct {
+		perTenant bool
+		want      string
+	}{
+		{false, "sync-relay:events"},
+		{true, "sync-relay:events:tenant-1"},
+	}
+
+	for _, tt := range tests {
+		if got := StreamKey("sync-relay:events", "tenant-1", tt.perTenant); got != tt.want {
+			t.Errorf("StreamKey(perTenant=%v) = %q, want %q", tt.perTenant, got, tt.want)
+		}
+	}
+}
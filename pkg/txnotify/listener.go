@@ -0,0 +1,96 @@
+package txnotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type Handler func(ctx context.Context, change *Change)
+
+type Listener struct {
+	databaseURL string
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+func NewListener(databaseURL string) *Listener {
+	return &Listener{
+		databaseURL: databaseURL,
+		minBackoff:  time.Second,
+		maxBackoff:  30 * time.Second,
+	}
+}
+
+// Listen delivers changes to handle until ctx is cancelled, reconnecting with
+// backoff whenever the connection drops.
+func (l *Listener) Listen(ctx context.Context, handle Handler) error {
+	backoff := l.minBackoff
+
+	for {
+		connected, err := l.listenOnce(ctx, handle)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// a session that got going resets the backoff
+		if connected {
+			backoff = l.minBackoff
+		}
+
+		slog.Warn("transaction change listener disconnected, reconnecting", "retry_in", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, l.maxBackoff)
+	}
+}
+
+func (l *Listener) listenOnce(ctx context.Context, handle Handler) (bool, error) {
+	conn, err := pgx.Connect(ctx, l.databaseURL)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer func() { _ = conn.Close(context.Background()) }()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return false, fmt.Errorf("failed to listen on %s: %w", Channel, err)
+	}
+
+	slog.Info("listening for transaction changes", "channel", Channel)
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+
+		change, err := decodeChange(n.Payload)
+		if err != nil {
+			slog.Warn("ignoring malformed transaction change", "payload", n.Payload, "error", err)
+			continue
+		}
+
+		handle(ctx, change)
+	}
+}
+
+func decodeChange(payload string) (*Change, error) {
+	var change Change
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		return nil, err
+	}
+	if change.ItemID == uuid.Nil || change.Kind == "" {
+		return nil, fmt.Errorf("change without item id or kind")
+	}
+	return &change, nil
+}
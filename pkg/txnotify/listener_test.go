@@ -0,0 +1,66 @@
+package txnotify
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDecodeChange(t *testing.T) {
+	itemID := uuid.New()
+
+	tests := []struct {
+		name    string
+		payload string
+		want    *Change
+	}{
+		{
+			name:    "removed",
+			payload: `{"item_id":"` + itemID.String() + `","kind":"removed","ids":["tx-1","tx-2"]}`,
+			want:    &Change{ItemID: itemID, Kind: KindRemoved, TransactionIDs: []string{"tx-1", "tx-2"}},
+		},
+		{name: "not json", payload: "tx-1"},
+		{name: "bad item id", payload: `{"item_id":"nope","kind":"added","ids":["tx-1"]}`},
+		{name: "no item id", payload: `{"kind":"added","ids":["tx-1"]}`},
+		{name: "no kind", payload: `{"item_id":"` + itemID.String() + `","ids":["tx-1"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeChange(tt.payload)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("decoded %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ItemID != tt.want.ItemID || got.Kind != tt.want.Kind || !slices.Equal(got.TransactionIDs, tt.want.TransactionIDs) {
+				t.Fatalf("decoded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeChangeReadsPayloads(t *testing.T) {
+	itemID := uuid.New()
+
+	payloads, err := Payloads(itemID, KindModified, []string{"tx-1", "tx-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) != 1 {
+		t.Fatalf("%d payloads, want 1", len(payloads))
+	}
+
+	change, err := decodeChange(payloads[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change.ItemID != itemID || change.Kind != KindModified || !slices.Equal(change.TransactionIDs, []string{"tx-1", "tx-2"}) {
+		t.Fatalf("decoded %+v, want what was sent", change)
+	}
+}
@@ -0,0 +1,77 @@
+// Package txnotify listens for the pg_notify messages sync-relay sends on
+// the transaction_changes channel when transaction rows are written.
+// notifications sent while the listener is disconnected are not replayed,
+// treat them as hints to re-read the rows rather than a complete log.
+package txnotify
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+const Channel = "transaction_changes"
+
+// postgres rejects notify payloads of 8000 bytes or more, larger changes are
+// split across several notifications
+const MaxPayloadBytes = 7900
+
+type Kind string
+
+const (
+	KindAdded    Kind = "added"
+	KindModified Kind = "modified"
+	KindRemoved  Kind = "removed"
+)
+
+type Change struct {
+	ItemID uuid.UUID `json:"item_id"`
+	Kind   Kind      `json:"kind"`
+	// plaid transaction ids
+	TransactionIDs []string `json:"ids"`
+}
+
+// Payloads encodes a change as notify payloads of at most MaxPayloadBytes,
+// splitting ids across as many as it takes. an id too long to fit on its own
+// still gets a payload, which postgres will reject.
+func Payloads(itemID uuid.UUID, kind Kind, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	envelope, err := json.Marshal(Change{ItemID: itemID, Kind: kind, TransactionIDs: []string{}})
+	if err != nil {
+		return nil, err
+	}
+
+	var payloads []string
+	for len(ids) > 0 {
+		size := len(envelope)
+		n := 0
+		for n < len(ids) {
+			id, err := json.Marshal(ids[n])
+			if err != nil {
+				return nil, err
+			}
+			// every id after the first needs a comma
+			grow := len(id)
+			if n > 0 {
+				grow++
+			}
+			if n > 0 && size+grow > MaxPayloadBytes {
+				break
+			}
+			size += grow
+			n++
+		}
+
+		payload, err := json.Marshal(Change{ItemID: itemID, Kind: kind, TransactionIDs: ids[:n]})
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, string(payload))
+		ids = ids[n:]
+	}
+
+	return payloads, nil
+}
@@ -0,0 +1,84 @@
+package txnotify
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPayloads(t *testing.T) {
+	itemID := uuid.New()
+
+	envelope, err := json.Marshal(Change{ItemID: itemID, Kind: KindAdded, TransactionIDs: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bytes left for the ids of a single payload, an id costs its length
+	// plus two quotes, every id after the first a comma too
+	room := MaxPayloadBytes - len(envelope)
+
+	many := make([]string, 2000)
+	for i := range many {
+		many[i] = fmt.Sprintf("tx-%05d", i)
+	}
+
+	tests := []struct {
+		name         string
+		ids          []string
+		wantPayloads int
+		// the first payload is exactly MaxPayloadBytes
+		full      bool
+		oversized bool
+	}{
+		{name: "no ids", ids: nil, wantPayloads: 0},
+		{name: "one id exactly at the limit", ids: []string{strings.Repeat("a", room-2)}, wantPayloads: 1, full: true},
+		// "tx-1" and a comma take 7 bytes
+		{name: "two ids exactly at the limit", ids: []string{"tx-1", strings.Repeat("b", room-7-2)}, wantPayloads: 1, full: true},
+		{name: "two ids one byte over", ids: []string{"tx-1", strings.Repeat("b", room-7-2+1)}, wantPayloads: 2},
+		// 11 bytes an id with its comma, about 710 to a payload
+		{name: "many short ids", ids: many, wantPayloads: 3},
+		// json escapes < as <, six bytes for one
+		{name: "escaped ids", ids: []string{strings.Repeat("<", room/12), strings.Repeat("<", room/12)}, wantPayloads: 2},
+		{name: "an id too long for any payload", ids: []string{strings.Repeat("c", room)}, wantPayloads: 1, oversized: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payloads, err := Payloads(itemID, KindAdded, tt.ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(payloads) != tt.wantPayloads {
+				t.Fatalf("%d payloads, want %d", len(payloads), tt.wantPayloads)
+			}
+			if tt.full && len(payloads[0]) != MaxPayloadBytes {
+				t.Fatalf("payload of %d bytes, want exactly %d", len(payloads[0]), MaxPayloadBytes)
+			}
+
+			var got []string
+			for _, p := range payloads {
+				if len(p) > MaxPayloadBytes && !tt.oversized {
+					t.Fatalf("payload of %d bytes, limit is %d", len(p), MaxPayloadBytes)
+				}
+
+				var change Change
+				if err := json.Unmarshal([]byte(p), &change); err != nil {
+					t.Fatal(err)
+				}
+				if change.ItemID != itemID || change.Kind != KindAdded || len(change.TransactionIDs) == 0 {
+					t.Fatalf("payload %s, want ids for the item", p)
+				}
+				got = append(got, change.TransactionIDs...)
+			}
+
+			// every id is sent once, in order
+			if !slices.Equal(got, tt.ids) {
+				t.Fatalf("payloads carry %d ids, want the %d given in order", len(got), len(tt.ids))
+			}
+		})
+	}
+}